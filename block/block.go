@@ -1,8 +1,9 @@
 package block
 
 import (
-	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -13,14 +14,17 @@ type Block struct {
 	nonce        int
 	previousHash types.Byte32
 	timestamp    int64
+	// bits is the compact target the block's hash has to meet
+	bits         uint32
 	transactions []*Transaction
 }
 
-func NewBlock(nonce int, previousHash types.Byte32, timestamp int64, transactions []*Transaction) *Block {
+func NewBlock(nonce int, previousHash types.Byte32, timestamp int64, bits uint32, transactions []*Transaction) *Block {
 	return &Block{
 		nonce:        nonce,
 		previousHash: previousHash,
 		timestamp:    timestamp,
+		bits:         bits,
 		transactions: transactions,
 	}
 }
@@ -29,7 +33,9 @@ func (b *Block) Print() {
 	fmt.Printf("%s\n", strings.Repeat("-", 40))
 	fmt.Printf("timestamp        %d\n", b.timestamp)
 	fmt.Printf("nonce            %d\n", b.nonce)
+	fmt.Printf("bits             %08x\n", b.bits)
 	fmt.Printf("previousHash     %x\n", b.previousHash)
+	fmt.Printf("merkleRoot       %x\n", MerkleRoot(b.transactionIDs()))
 	fmt.Printf("hash             %x\n", b.Hash())
 	for _, t := range b.transactions {
 		t.Print()
@@ -37,21 +43,76 @@ func (b *Block) Print() {
 	fmt.Printf("%s\n", strings.Repeat("-", 40))
 }
 
+// Hash is the hash of the block's header.
 func (b *Block) Hash() types.Byte32 {
-	m, _ := json.Marshal(b)
-	return sha256.Sum256(m)
+	return b.Header().Hash()
+}
+
+func (b *Block) ValidProof() bool {
+	return b.Header().ValidProof()
 }
 
 func (b *Block) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		Timestamp    int64          `json:"timestamp"`
 		Nonce        int            `json:"nonce"`
+		Bits         uint32         `json:"bits"`
 		PreviousHash string         `json:"previous_hash"`
+		MerkleRoot   string         `json:"merkle_root"`
 		Transactions []*Transaction `json:"transactions"`
 	}{
 		Timestamp:    b.timestamp,
 		Nonce:        b.nonce,
+		Bits:         b.bits,
 		PreviousHash: fmt.Sprintf("%x", b.previousHash),
+		MerkleRoot:   fmt.Sprintf("%x", MerkleRoot(b.transactionIDs())),
 		Transactions: b.transactions,
 	})
 }
+
+// UnmarshalJSON reads a block written by MarshalJSON. The decoded block
+// marshals back to the same bytes, so its Hash matches the original's. The
+// merkle_root is derived from the transactions and must agree with them.
+func (b *Block) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		Timestamp    *int64          `json:"timestamp"`
+		Nonce        *int            `json:"nonce"`
+		Bits         *uint32         `json:"bits"`
+		PreviousHash *string         `json:"previous_hash"`
+		MerkleRoot   *string         `json:"merkle_root"`
+		Transactions json.RawMessage `json:"transactions"`
+	}{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	if v.Timestamp == nil || v.Nonce == nil || v.Bits == nil || v.PreviousHash == nil || v.MerkleRoot == nil || v.Transactions == nil {
+		return errors.New("block is missing timestamp, nonce, bits, previous_hash, merkle_root or transactions")
+	}
+	ph, err := hex.DecodeString(*v.PreviousHash)
+	if err != nil {
+		return fmt.Errorf("malformed previous_hash: %w", err)
+	}
+	if len(ph) != len(b.previousHash) {
+		return fmt.Errorf("previous_hash must be %d bytes, got %d", len(b.previousHash), len(ph))
+	}
+	// a null list stays nil so that it marshals back to null
+	var transactions []*Transaction
+	if err := json.Unmarshal(v.Transactions, &transactions); err != nil {
+		return err
+	}
+	for _, t := range transactions {
+		if t == nil {
+			return errors.New("block contains a null transaction")
+		}
+	}
+	decoded := Block{transactions: transactions}
+	if root := fmt.Sprintf("%x", MerkleRoot(decoded.transactionIDs())); root != *v.MerkleRoot {
+		return fmt.Errorf("merkle_root %s does not match the transactions' root %s", *v.MerkleRoot, root)
+	}
+	b.timestamp = *v.Timestamp
+	b.nonce = *v.Nonce
+	b.bits = *v.Bits
+	copy(b.previousHash[:], ph)
+	b.transactions = transactions
+	return nil
+}
@@ -1,9 +1,16 @@
 package block
 
 import (
+	types "blockchain/blockchaintypes"
 	"blockchain/globals"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"math/rand"
+	"reflect"
 	"testing"
+	"testing/quick"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -21,22 +28,97 @@ func TestBlock_Hash(t *testing.T) {
 		{
 			recipientBlockchainAddress: "A",
 			senderBlockchainAddress:    "B",
-			value:                      100.5,
+			value:                      100*types.Coin + types.Coin/2,
 		},
 		{
 			recipientBlockchainAddress: "C",
 			senderBlockchainAddress:    "D",
-			value:                      200.5,
+			value:                      200*types.Coin + types.Coin/2,
 		},
 	}
 
-	block := NewBlock(nonce, previousHash, timestamp, transactions)
+	block := NewBlock(nonce, previousHash, timestamp, 0x1f0fffff, transactions)
 	block.Print()
 
 	Convey("block1 was created as expected", t, func() {
 		So(block.nonce, ShouldEqual, nonce)
 		So(block.timestamp, ShouldEqual, timestamp)
 		So(block.previousHash, ShouldEqual, previousHash)
-		So(fmt.Sprintf("%x", block.Hash()), ShouldEqual, "1d1211bd8a6d46d981645464d1ee91b4b43673c8d42efa48e05bbcd2b21c2aca")
+		So(fmt.Sprintf("%x", block.Hash()), ShouldEqual, "002336a9533dd19e163882815c15b119c2596f567d948be09af537964733683c")
+	})
+}
+
+func TestBlock_Header(t *testing.T) {
+	transactions := []*Transaction{
+		NewTransaction(MiningSender, "A", MiningReward),
+		NewTransaction("A", "B", types.Coin/2),
+	}
+	b := NewBlock(42, types.Byte32{1}, BlockTimestamp, 0x1f0fffff, transactions)
+
+	Convey("the block hash is the header hash", t, func() {
+		header := b.Header()
+		So(b.Hash(), ShouldEqual, header.Hash())
+		So(header.MerkleRoot(), ShouldEqual, MerkleRoot([]types.Byte32{transactions[0].ID(), transactions[1].ID()}))
+
+		m, err := json.Marshal(header)
+		So(err, ShouldBeNil)
+		decoded := new(BlockHeader)
+		So(json.Unmarshal(m, decoded), ShouldBeNil)
+		So(decoded, ShouldResemble, header)
+	})
+
+	Convey("transactions only reach the hash through the merkle root", t, func() {
+		reordered := NewBlock(42, types.Byte32{1}, BlockTimestamp, 0x1f0fffff, []*Transaction{transactions[1], transactions[0]})
+		So(reordered.Hash(), ShouldNotEqual, b.Hash())
+
+		// a signature isn't part of a transaction's id, so it isn't mined either
+		signed := NewSignedTransaction("A", "B", types.Coin/2, 0, 0, nil, &globals.Signature{R: big.NewInt(1), S: big.NewInt(2)})
+		resigned := NewBlock(42, types.Byte32{1}, BlockTimestamp, 0x1f0fffff, []*Transaction{transactions[0], signed})
+		So(resigned.Hash(), ShouldEqual, b.Hash())
+	})
+}
+
+func (b *Block) Generate(r *rand.Rand, _ int) reflect.Value {
+	var transactions []*Transaction
+	if n := r.Intn(6); n > 0 || r.Intn(2) == 0 {
+		transactions = make([]*Transaction, n)
+		for i := range transactions {
+			transactions[i] = randomTransaction(r)
+		}
+	}
+	b = NewBlock(r.Int(), [32]byte{}, r.Int63(), r.Uint32(), transactions)
+	r.Read(b.previousHash[:])
+	return reflect.ValueOf(b)
+}
+
+func TestBlock_UnmarshalJSON(t *testing.T) {
+	Convey("blocks round-trip through JSON with an unchanged hash", t, func() {
+		property := func(original *Block) bool {
+			m, err := json.Marshal(original)
+			if err != nil {
+				return false
+			}
+			decoded := new(Block)
+			if err := json.Unmarshal(m, decoded); err != nil {
+				return false
+			}
+			again, _ := json.Marshal(decoded)
+			return bytes.Equal(m, again) && decoded.Hash() == original.Hash()
+		}
+		So(quick.Check(property, nil), ShouldBeNil)
+	})
+
+	Convey("malformed blocks are rejected", t, func() {
+		for _, data := range []string{
+			`{}`,
+			`{"timestamp":1,"nonce":1,"previous_hash":"00","transactions":[]}`,
+			`{"timestamp":1,"nonce":1,"previous_hash":"xyz","transactions":[]}`,
+			`{"timestamp":1,"nonce":1,"transactions":[]}`,
+			`{"timestamp":1,"nonce":1,"previous_hash":"0000000000000000000000000000000000000000000000000000000000000000","transactions":[null]}`,
+			`{"timestamp":1,"nonce":1,"bits":1,"previous_hash":"0000000000000000000000000000000000000000000000000000000000000000","transactions":[]}`,
+			`{"timestamp":1,"nonce":1,"bits":1,"previous_hash":"0000000000000000000000000000000000000000000000000000000000000000","merkle_root":"0000000000000000000000000000000000000000000000000000000000000001","transactions":[]}`,
+		} {
+			So(json.Unmarshal([]byte(data), new(Block)), ShouldNotBeNil)
+		}
 	})
 }
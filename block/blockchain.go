@@ -3,69 +3,246 @@ package block
 import (
 	types "blockchain/blockchaintypes"
 	"blockchain/globals"
+	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
 	"sync"
 	"time"
 
 	"fmt"
+	"math/big"
 	"strings"
 )
 
 const (
 	MiningDifficulty = 3
 	MiningSender     = "THE BLOCKCHAIN"
-	MiningReward     = 1.0
+	MiningReward     = 1 * types.Coin
 	MiningTimerSec   = 20
+	// MaxBlockTransactions caps the transactions of a block, coinbase
+	// included.
+	MaxBlockTransactions = 100
 
 	BlockchainPortRangeStart      = 5001
 	BlockchainPortRangeEnd        = 5004
 	NeighborIpRangeStart          = 0
 	NeighborIpRangeEnd            = 1
 	BlockchainNeighborSyncTimeSec = 20
+	BlockchainRequestTimeoutSec   = 5
 )
 
 type Blockchain struct {
-	globals           globals.IGlobalLib
-	transactionPool   []*Transaction
-	chain             []*Block
+	globals         globals.IGlobalLib
+	storage         Storage
+	genesis         *Genesis
+	genesisHash     types.Byte32
+	mode            LedgerMode
+	transactionPool *Mempool
+	chain           []*Block
+	// indexes over chain, kept up to date by extendChain and truncateChain
+	blockIndex        map[types.Byte32]int
+	chainWork         []*big.Int
+	chainSupply       []types.Amount
+	transactionIndex  map[types.Byte32]int
+	balances          map[string]types.Amount
+	nonces            map[string]uint64
+	utxos             map[types.OutPoint]types.TxOutput
+	addressIndex      map[string][]transactionRef
 	blockchainAddress string
 	port              uint16
 	mux               sync.Mutex
+	miner             *Miner
+	muxMining         sync.Mutex
+	cancelMining      context.CancelFunc
 	neighbors         []string
 	muxNeighbors      sync.Mutex
 }
 
 type AmountResponse struct {
-	Amount float32 `json:"amount"`
+	Amount types.Amount `json:"amount"`
 }
 
 func (ar *AmountResponse) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Amount float32 `json:"amount"`
+		Amount types.Amount `json:"amount"`
 	}{
 		Amount: ar.Amount,
 	})
 }
 
-func NewBlockchain(globals globals.IGlobalLib) *Blockchain {
+// TransactionResponse answers POST /transactions. ID is set whenever the
+// request held a well-formed transaction, also when it was rejected.
+type TransactionResponse struct {
+	Message string `json:"message"`
+	ID      string `json:"id,omitempty"`
+}
+
+// BlockResponse is a block as served by the explorer endpoints, along with
+// the values a client would otherwise have to compute.
+type BlockResponse struct {
+	Height           int    `json:"height"`
+	Hash             string `json:"hash"`
+	TransactionCount int    `json:"transaction_count"`
+	Block            *Block `json:"block"`
+}
+
+const (
+	TransactionPending   = "pending"
+	TransactionConfirmed = "confirmed"
+)
+
+// TransactionStatus tells where a transaction stands. BlockHeight is only set
+// once the transaction has been mined; the block holding it counts as the
+// first confirmation.
+type TransactionStatus struct {
+	ID            string       `json:"id"`
+	Status        string       `json:"status"`
+	BlockHeight   *int         `json:"block_height,omitempty"`
+	Confirmations int          `json:"confirmations"`
+	Transaction   *Transaction `json:"transaction"`
+}
+
+// TransactionProof shows that a transaction was mined: hashing ID up the
+// Branch gives the header's merkle root, and the header hashes to BlockHash.
+type TransactionProof struct {
+	ID          string       `json:"id"`
+	BlockHeight int          `json:"block_height"`
+	BlockHash   string       `json:"block_hash"`
+	Header      *BlockHeader `json:"header"`
+	Branch      []MerkleStep `json:"branch"`
+}
+
+// Verify reports whether the proof is consistent, i.e. whether the
+// transaction is part of the block whose header it carries.
+func (p *TransactionProof) Verify() bool {
+	id, err := types.ParseByte32(p.ID)
+	if err != nil || p.Header == nil {
+		return false
+	}
+	return fmt.Sprintf("%x", p.Header.Hash()) == p.BlockHash &&
+		VerifyMerkleBranch(id, p.Branch, p.Header.MerkleRoot())
+}
+
+// NonceResponse answers GET /address/{addr}/nonce. Nonce is the one to sign
+// the next transaction with: ConfirmedNonce, the next the chain takes, moved
+// on past the transactions already waiting in the pool.
+type NonceResponse struct {
+	Address        string `json:"address"`
+	Nonce          uint64 `json:"nonce"`
+	ConfirmedNonce uint64 `json:"confirmed_nonce"`
+}
+
+// GenesisResponse identifies the network a node belongs to.
+type GenesisResponse struct {
+	NetworkID string `json:"network_id"`
+	Hash      string `json:"hash"`
+}
+
+// NewBlockchain restores the chain and transaction pool kept in storage. An
+// empty storage starts out with the genesis block described by genesis, and a
+// stored chain with a different genesis block is refused. mode decides
+// whether transactions draw on account balances or spend unspent outputs;
+// every node of a network has to use the same.
+func NewBlockchain(globals globals.IGlobalLib, storage Storage, genesis *Genesis, mode LedgerMode) (*Blockchain, error) {
+	if err := genesis.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid genesis: %w", err)
+	}
 	bc := new(Blockchain)
 	bc.blockchainAddress = "my_blockchain_address"
 	bc.globals = globals
-	b0 := NewBlock(0, globals.EmptyByte32(), globals.NowUnixNano(), []*Transaction{})
-	bc.chain = append(bc.chain, b0)
-	return bc
+	bc.storage = storage
+	bc.genesis = genesis
+	bc.genesisHash = genesis.Hash()
+	bc.mode = mode
+	bc.blockIndex = make(map[types.Byte32]int)
+	bc.transactionIndex = make(map[types.Byte32]int)
+	bc.balances = make(map[string]types.Amount)
+	bc.nonces = make(map[string]uint64)
+	bc.utxos = make(map[types.OutPoint]types.TxOutput)
+	bc.addressIndex = make(map[string][]transactionRef)
+	bc.miner = NewMiner(0)
+	bc.transactionPool = NewMempool(MempoolConfig{})
+	if err := bc.load(); err != nil {
+		return nil, err
+	}
+	return bc, nil
+}
+
+func (bc *Blockchain) load() error {
+	chain, err := bc.storage.Blocks()
+	if err != nil {
+		return err
+	}
+	if len(chain) == 0 {
+		b0 := bc.genesis.Block()
+		if err := bc.storage.AppendBlock(b0); err != nil {
+			return err
+		}
+		chain = append(chain, b0)
+	}
+
+	// keep everything up to the first block that doesn't check out
+	if err := bc.ValidChain(chain); err != nil {
+		var ce *ChainError
+		if !errors.As(err, &ce) || ce.Height == 0 {
+			return fmt.Errorf("stored chain is unusable: %w", err)
+		}
+		log.Printf("action=load_chain status=truncated height=%d reason=%q", ce.Height, err)
+		chain = chain[:ce.Height]
+		if err := bc.storage.ReplaceBlocks(chain); err != nil {
+			return err
+		}
+	}
+	bc.setChain(chain)
+
+	pending, err := bc.storage.Transactions()
+	if err != nil {
+		return err
+	}
+	now := bc.globals.NowUnixNano()
+	for _, p := range pending {
+		arrival := p.Arrival
+		if arrival == 0 {
+			// saved before arrival times were kept
+			arrival = now
+		}
+		if bc.transactionPool.Expired(arrival, now) {
+			log.Printf("action=load_transaction status=expired id=%x", p.Transaction.ID())
+			continue
+		}
+		if err := bc.admitTransaction(p.Transaction, arrival); err != nil {
+			log.Printf("action=load_transaction status=dropped id=%x reason=%q", p.Transaction.ID(), err)
+		}
+	}
+	bc.saveTransactionPool()
+	log.Printf("action=load_chain network=%s genesis=%x height=%d transactions=%d",
+		bc.genesis.NetworkID, bc.genesisHash, len(bc.chain)-1, bc.transactionPool.Len())
+	return nil
+}
+
+// saveTransactionPool persists the pool. A lost snapshot only costs pending
+// transactions, so failures are logged rather than returned. Callers must
+// hold bc.mux.
+func (bc *Blockchain) saveTransactionPool() {
+	if err := bc.storage.SaveTransactions(bc.transactionPool.Pending()); err != nil {
+		log.Printf("ERROR: saving transaction pool failed: %v", err)
+	}
 }
 
 func (bc *Blockchain) Run() {
 	bc.StartSyncNeighbors()
 }
 
+// SetNeighbors scans for other nodes and keeps those that run on the same
+// network, i.e. started from the same genesis block.
 func (bc *Blockchain) SetNeighbors() {
 	myHost := globals.GetHost()
-	bc.neighbors = globals.FindNeighbors(
+	candidates := globals.FindNeighbors(
 		myHost,
 		bc.port,
 		NeighborIpRangeStart,
@@ -73,6 +250,15 @@ func (bc *Blockchain) SetNeighbors() {
 		BlockchainPortRangeStart,
 		BlockchainPortRangeEnd,
 	)
+	neighbors := make([]string, 0, len(candidates))
+	for _, n := range candidates {
+		if err := bc.CheckGenesis(n); err != nil {
+			log.Printf("action=sync_neighbors status=skipped neighbor=%s reason=%q", n, err)
+			continue
+		}
+		neighbors = append(neighbors, n)
+	}
+	bc.neighbors = neighbors
 }
 
 func (bc *Blockchain) SyncNeighbors() {
@@ -86,22 +272,127 @@ func (bc *Blockchain) StartSyncNeighbors() {
 	_ = time.AfterFunc(time.Second*BlockchainNeighborSyncTimeSec, bc.StartSyncNeighbors)
 }
 
+func (bc *Blockchain) AddNeighbor(address string) {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	bc.neighbors = append(bc.neighbors, address)
+}
+
+func (bc *Blockchain) Neighbors() []string {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	return append([]string(nil), bc.neighbors...)
+}
+
+func (bc *Blockchain) Genesis() *Genesis {
+	return bc.genesis
+}
+
+func (bc *Blockchain) GenesisHash() types.Byte32 {
+	return bc.genesisHash
+}
+
+// CheckGenesis asks a neighbor for its genesis block hash via GET /genesis
+// and returns ErrGenesisMismatch when it differs from ours.
+func (bc *Blockchain) CheckGenesis(neighbor string) error {
+	client := &http.Client{Timeout: time.Second * BlockchainRequestTimeoutSec}
+	resp, err := client.Get(fmt.Sprintf("http://%s/genesis", neighbor))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var gr GenesisResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gr); err != nil {
+		return err
+	}
+	if gr.Hash != fmt.Sprintf("%x", bc.genesisHash) {
+		return fmt.Errorf("%w: network %q, genesis %s", ErrGenesisMismatch, gr.NetworkID, gr.Hash)
+	}
+	return nil
+}
+
+// TransactionPool returns the pending transactions, highest fee rate first.
 func (bc *Blockchain) TransactionPool() []*Transaction {
-	return bc.transactionPool
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.transactionPool.Transactions()
+}
+
+// SetMempoolConfig changes the bounds of the transaction pool.
+func (bc *Blockchain) SetMempoolConfig(config MempoolConfig) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.transactionPool.SetConfig(config)
+	bc.saveTransactionPool()
 }
 
+// MempoolStats describes the transaction pool after dropping what expired.
+func (bc *Blockchain) MempoolStats() *MempoolStats {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if bc.transactionPool.Expire(bc.globals.NowUnixNano()) > 0 {
+		bc.saveTransactionPool()
+	}
+	return bc.transactionPool.Stats()
+}
+
+// MarshalJSON encodes the chain as it is when called. Only the copy of the
+// block list is taken under bc.mux, blocks don't change once appended.
 func (bc *Blockchain) MarshalJSON() ([]byte, error) {
+	bc.mux.Lock()
+	chain := append([]*Block(nil), bc.chain...)
+	bc.mux.Unlock()
+
 	return json.Marshal(struct {
 		Blocks []*Block `json:"blocks"`
 	}{
-		Blocks: bc.chain,
+		Blocks: chain,
 	})
 }
 
+// UnmarshalJSON restores the chain written by MarshalJSON. The transaction
+// pool and node settings are not part of the encoding.
+func (bc *Blockchain) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		Blocks *[]*Block `json:"blocks"`
+	}{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	if v.Blocks == nil {
+		return errors.New("blockchain is missing blocks")
+	}
+	for _, b := range *v.Blocks {
+		if b == nil {
+			return errors.New("blockchain contains a null block")
+		}
+	}
+	bc.chain = *v.Blocks
+	return nil
+}
+
 func (bc *Blockchain) SetBlockchainAddress(address string) {
 	bc.blockchainAddress = address
 }
 
+// SetMiningWorkers sets the number of goroutines searching for a proof of
+// work, one per CPU when workers isn't positive.
+func (bc *Blockchain) SetMiningWorkers(workers int) {
+	bc.muxMining.Lock()
+	defer bc.muxMining.Unlock()
+	bc.miner = NewMiner(workers)
+}
+
+func (bc *Blockchain) Miner() *Miner {
+	bc.muxMining.Lock()
+	defer bc.muxMining.Unlock()
+	return bc.miner
+}
+
 func (bc *Blockchain) SetPort(port uint16) {
 	bc.port = port
 }
@@ -115,129 +406,468 @@ func (bc *Blockchain) Print() {
 	fmt.Printf("%s\n", strings.Repeat("*", 39))
 }
 
-func (bc *Blockchain) CreateBlock() *Block {
-	lb := bc.LastBlock()
-	previousHash := lb.Hash()
-	nonce := bc.ProofOfWork()
-	timestamp := bc.globals.NowUnixNano()
-	b := NewBlock(nonce, previousHash, timestamp, bc.transactionPool)
-	bc.chain = append(bc.chain, b)
-	bc.transactionPool = []*Transaction{}
-	return b
-}
-
 func (bc *Blockchain) LastBlock() *Block {
 	return bc.chain[len(bc.chain)-1]
 }
 
+// blockResponse describes the block at height. Callers must hold bc.mux.
+func (bc *Blockchain) blockResponse(height int) *BlockResponse {
+	b := bc.chain[height]
+	return &BlockResponse{
+		Height:           height,
+		Hash:             fmt.Sprintf("%x", b.Hash()),
+		TransactionCount: len(b.transactions),
+		Block:            b,
+	}
+}
+
+// Blocks returns up to limit blocks starting at height from.
+func (bc *Blockchain) Blocks(from int, limit int) []*BlockResponse {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	blocks := make([]*BlockResponse, 0, limit)
+	for height := from; height >= 0 && height < len(bc.chain) && len(blocks) < limit; height++ {
+		blocks = append(blocks, bc.blockResponse(height))
+	}
+	return blocks
+}
+
+// Headers returns up to limit block headers starting at height from.
+func (bc *Blockchain) Headers(from int, limit int) []*BlockHeader {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	headers := make([]*BlockHeader, 0, limit)
+	for height := from; height >= 0 && height < len(bc.chain) && len(headers) < limit; height++ {
+		headers = append(headers, bc.chain[height].Header())
+	}
+	return headers
+}
+
+func (bc *Blockchain) BlockByHeight(height int) (*BlockResponse, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if height < 0 || height >= len(bc.chain) {
+		return nil, false
+	}
+	return bc.blockResponse(height), true
+}
+
+func (bc *Blockchain) BlockByHash(hash types.Byte32) (*BlockResponse, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	height, ok := bc.blockIndex[hash]
+	if !ok {
+		return nil, false
+	}
+	return bc.blockResponse(height), true
+}
+
+func (bc *Blockchain) LatestBlock() *BlockResponse {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.blockResponse(len(bc.chain) - 1)
+}
+
+// Height is the height of the last block; the genesis block is at height 0.
+func (bc *Blockchain) Height() int {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return len(bc.chain) - 1
+}
+
 func (bc *Blockchain) VerifyTransactionSignature(
 	senderPublicKey *ecdsa.PublicKey,
 	s *globals.Signature,
 	t *Transaction,
 ) bool {
-	m, _ := json.Marshal(t)
+	if senderPublicKey == nil || s == nil {
+		return false
+	}
+	m, _ := t.SignedPayload()
 	h := sha256.Sum256([]byte(m))
 	return ecdsa.Verify(senderPublicKey, h[:], s.R, s.S)
 }
 
+// CreateTransaction adds a transaction received from a client or a neighbor
+// and relays it to the other neighbors once it has been accepted.
 func (bc *Blockchain) CreateTransaction(
 	sender string,
 	recipient string,
-	value float32,
+	value types.Amount,
+	fee types.Amount,
+	nonce uint64,
 	senderPublicKey *ecdsa.PublicKey,
-	s *globals.Signature) bool {
+	s *globals.Signature) error {
 
-	isTransacted := bc.AddTransaction(
+	err := bc.AddTransaction(
 		sender,
 		recipient,
 		value,
+		fee,
+		nonce,
 		senderPublicKey,
 		s)
 
-	return isTransacted
+	if err == nil {
+		bc.relayTransaction(NewSignedTransaction(sender, recipient, value, fee, nonce, senderPublicKey, s))
+	}
+	return err
 }
 
+// AddTransaction puts a signed transfer into the transaction pool. It is
+// rejected when the sender address isn't the one of the signing key, when the
+// sender can't cover its value and fee with the confirmed balance minus what
+// is already pending, when the same signed transfer was seen before, or when
+// its nonce was used already. A nonce past the next one the chain expects is
+// queued until the transactions in between arrive.
 func (bc *Blockchain) AddTransaction(
 	sender string,
 	recipient string,
-	value float32,
+	value types.Amount,
+	fee types.Amount,
+	nonce uint64,
 	senderPublicKey *ecdsa.PublicKey,
-	s *globals.Signature) bool {
+	s *globals.Signature) error {
 
-	t := NewTransaction(sender, recipient, value)
+	return bc.submitTransaction(NewSignedTransaction(sender, recipient, value, fee, nonce, senderPublicKey, s))
+}
 
-	if sender == MiningSender {
-		bc.transactionPool = append(bc.transactionPool, t)
-		return true
+// CreateUTXOTransaction is CreateTransaction for a chain in UTXOMode.
+func (bc *Blockchain) CreateUTXOTransaction(
+	sender string,
+	inputs []types.OutPoint,
+	outputs []types.TxOutput,
+	fee types.Amount,
+	nonce uint64,
+	senderPublicKey *ecdsa.PublicKey,
+	s *globals.Signature) error {
+
+	err := bc.AddUTXOTransaction(sender, inputs, outputs, fee, nonce, senderPublicKey, s)
+	if err == nil {
+		bc.relayTransaction(NewSignedUTXOTransaction(sender, inputs, outputs, fee, nonce, senderPublicKey, s))
+	}
+	return err
+}
+
+// AddUTXOTransaction puts a transaction spending the sender's unspent
+// outputs into the transaction pool. The inputs have to be confirmed, belong
+// to the sender and add up to the outputs plus the fee, and no other pending
+// transaction may spend them. Nonces are enforced as for AddTransaction.
+func (bc *Blockchain) AddUTXOTransaction(
+	sender string,
+	inputs []types.OutPoint,
+	outputs []types.TxOutput,
+	fee types.Amount,
+	nonce uint64,
+	senderPublicKey *ecdsa.PublicKey,
+	s *globals.Signature) error {
+
+	return bc.submitTransaction(NewSignedUTXOTransaction(sender, inputs, outputs, fee, nonce, senderPublicKey, s))
+}
+
+func (bc *Blockchain) submitTransaction(t *Transaction) error {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if err := bc.admitTransaction(t, bc.globals.NowUnixNano()); err != nil {
+		return err
+	}
+	bc.saveTransactionPool()
+	return nil
+}
+
+// admitTransaction checks t against the chain and the pool and adds it to
+// the pool as having arrived at arrival. Callers must hold bc.mux.
+func (bc *Blockchain) admitTransaction(t *Transaction, arrival int64) error {
+	if t.senderBlockchainAddress == MiningSender {
+		return ErrReservedSender
+	}
+
+	if err := bc.verifySender(t); err != nil {
+		log.Printf("ERROR: Verify Transaction: %v", err)
+		return err
+	}
+
+	if t.value <= 0 {
+		return ErrInvalidValue
+	}
+
+	if err := fitsLedger(t, bc.mode); err != nil {
+		return err
+	}
+
+	if t.fee < 0 {
+		return ErrInvalidFee
+	}
+
+	cost, err := t.cost()
+	if err != nil {
+		return ErrInvalidValue
+	}
+
+	if bc.hasTransaction(t.ID()) {
+		log.Printf("action=add_transaction status=duplicate id=%x", t.ID())
+		return ErrDuplicateTransaction
+	}
+
+	expected := bc.nonces[t.senderBlockchainAddress]
+	if t.nonce < expected {
+		log.Printf("action=add_transaction status=nonce_too_low id=%x nonce=%d expected=%d", t.ID(), t.nonce, expected)
+		return ErrNonceTooLow
 	}
 
-	if bc.VerifyTransactionSignature(senderPublicKey, s, t) {
+	immature := bc.genesis.immatureCoinbases(bc.chain)
+	if bc.mode == UTXOMode {
+		if err := checkInputs(t, bc.unspentOutput, immatureOutPoints(immature)); err != nil {
+			log.Printf("action=add_transaction status=rejected id=%x reason=%q", t.ID(), err)
+			return err
+		}
+	} else if available, err := bc.spendableAmount(t.senderBlockchainAddress); err != nil || available < cost {
+		log.Println("ERROR: not enough balance in wallet")
+		return ErrInsufficientBalance
+	} else if available-immatureAmounts(immature)[t.senderBlockchainAddress] < cost {
+		log.Printf("action=add_transaction status=rejected id=%x reason=%q", t.ID(), ErrImmatureCoinbase)
+		return ErrImmatureCoinbase
+	}
+
+	bc.transactionPool.Expire(bc.globals.NowUnixNano())
+	if err := bc.transactionPool.Add(t, arrival); err != nil {
+		log.Printf("action=add_transaction status=rejected id=%x reason=%q", t.ID(), err)
+		return err
+	}
+	return nil
+}
+
+// spendableAmount is the confirmed balance of an address less the value and
+// fees of its transactions still waiting in the pool. Callers must hold
+// bc.mux.
+func (bc *Blockchain) spendableAmount(blockchainAddress string) (types.Amount, error) {
+	amount := bc.balances[blockchainAddress]
+	for _, t := range bc.transactionPool.Transactions() {
+		if t.senderBlockchainAddress == blockchainAddress {
+			cost, err := t.cost()
+			if err != nil {
+				return 0, err
+			}
+			if amount, err = amount.Sub(cost); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return amount, nil
+}
+
+// Nonce returns the nonce the next transaction of address should carry and
+// the one the chain expects next.
+func (bc *Blockchain) Nonce(blockchainAddress string) *NonceResponse {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
 
-		// if bc.CalculateTotalAmount(sender) < value {
-		// 	log.Println("ERROR: not enough balance in wallet")
-		// 	return false
-		// }
+	confirmed := bc.nonces[blockchainAddress]
+	return &NonceResponse{
+		Address:        blockchainAddress,
+		Nonce:          bc.transactionPool.NextNonce(blockchainAddress, confirmed),
+		ConfirmedNonce: confirmed,
+	}
+}
 
-		bc.transactionPool = append(bc.transactionPool, t)
+// hasTransaction reports whether the transaction is already pending or mined.
+// Callers must hold bc.mux.
+func (bc *Blockchain) hasTransaction(id types.Byte32) bool {
+	if bc.transactionPool.Has(id) {
 		return true
-	} else {
-		log.Println("ERROR: Verify Transaction")
-		return false
 	}
+	_, mined := bc.transactionIndex[id]
+	return mined
+}
+
+// TransactionStatus looks a transaction up in the pool and the chain. It
+// returns false when the node has never seen the transaction.
+func (bc *Blockchain) TransactionStatus(id types.Byte32) (*TransactionStatus, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	for _, t := range bc.transactionPool.Transactions() {
+		if t.ID() == id {
+			return &TransactionStatus{
+				ID:          fmt.Sprintf("%x", id),
+				Status:      TransactionPending,
+				Transaction: t,
+			}, true
+		}
+	}
+	height, ok := bc.transactionIndex[id]
+	if !ok {
+		return nil, false
+	}
+	for _, t := range bc.chain[height].transactions {
+		if t.ID() == id {
+			return &TransactionStatus{
+				ID:            fmt.Sprintf("%x", id),
+				Status:        TransactionConfirmed,
+				BlockHeight:   &height,
+				Confirmations: len(bc.chain) - height,
+				Transaction:   t,
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// TransactionProof returns a Merkle proof that the transaction with the given
+// id was mined. It reports false for pending and unknown transactions.
+func (bc *Blockchain) TransactionProof(id types.Byte32) (*TransactionProof, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	height, ok := bc.transactionIndex[id]
+	if !ok {
+		return nil, false
+	}
+	return bc.transactionProof(id, height)
+}
+
+// TransactionProofAt is TransactionProof for the block at height, for
+// clients that already know where the transaction was mined.
+func (bc *Blockchain) TransactionProofAt(id types.Byte32, height int) (*TransactionProof, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if height < 0 || height >= len(bc.chain) {
+		return nil, false
+	}
+	return bc.transactionProof(id, height)
+}
+
+// transactionProof proves id against the block at height. Callers must hold
+// bc.mux.
+func (bc *Blockchain) transactionProof(id types.Byte32, height int) (*TransactionProof, bool) {
+	b := bc.chain[height]
+	ids := b.transactionIDs()
+	for index, txID := range ids {
+		if txID == id {
+			return &TransactionProof{
+				ID:          fmt.Sprintf("%x", id),
+				BlockHeight: height,
+				BlockHash:   fmt.Sprintf("%x", b.Hash()),
+				Header:      b.Header(),
+				Branch:      MerkleBranch(ids, index),
+			}, true
+		}
+	}
+	return nil, false
 }
 
 func (bc *Blockchain) CopyTransactionPool() []*Transaction {
 	transactions := make([]*Transaction, 0)
-	for _, transaction := range bc.transactionPool {
-		transactions = append(transactions,
-			NewTransaction(
-				transaction.senderBlockchainAddress,
-				transaction.recipientBlockchainAddress,
-				transaction.value))
+	for _, transaction := range bc.transactionPool.Transactions() {
+		t := NewSignedTransaction(
+			transaction.senderBlockchainAddress,
+			transaction.recipientBlockchainAddress,
+			transaction.value,
+			transaction.fee,
+			transaction.nonce,
+			transaction.senderPublicKey,
+			transaction.signature)
+		t.inputs = transaction.inputs
+		t.outputs = transaction.outputs
+		transactions = append(transactions, t)
 	}
 	return transactions
 }
 
-func (bc *Blockchain) ValidProof(
-	nonce int,
-	previousHash types.Byte32,
-	transactions []*Transaction,
-	difficulty int) bool {
+// MineBlock mines a block from the transactions paying the highest fee rates
+// whose nonces are next in line, plus a coinbase carrying the miner's reward
+// and their fees. bc.mux is only held to take a snapshot of the pool and to
+// append the result, so the chain can be read and transactions accepted while
+// the workers search. The search ends early with ErrStaleBlock when another
+// block extends the chain first, or with ctx.Err() when ctx is done.
+func (bc *Blockchain) MineBlock(ctx context.Context) (*Block, error) {
+	bc.muxMining.Lock()
+	defer bc.muxMining.Unlock()
 
-	zeros := strings.Repeat("0", difficulty)
-	guessBlock := Block{
-		timestamp:    0,
-		nonce:        nonce,
-		previousHash: previousHash,
-		transactions: transactions,
-	}
-	guessHashStr := fmt.Sprintf("%x", guessBlock.Hash())
-	return guessHashStr[:difficulty] == zeros
-}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-func (bc *Blockchain) ProofOfWork() int {
-	transactions := bc.CopyTransactionPool()
+	bc.mux.Lock()
+	if bc.transactionPool.Expire(bc.globals.NowUnixNano()) > 0 {
+		bc.saveTransactionPool()
+	}
 	previousHash := bc.LastBlock().Hash()
-	nonce := 0
-	for !bc.ValidProof(nonce, previousHash, transactions, MiningDifficulty) {
-		nonce += 1
+	transactions := bc.transactionPool.Executable(func(sender string) uint64 {
+		return bc.nonces[sender]
+	}, MaxBlockTransactions-1)
+	reward := bc.genesis.Reward(len(bc.chain), bc.chainSupply[len(bc.chain)-1])
+	for _, t := range transactions {
+		// the pool only holds transactions their senders can pay for, so
+		// their fees don't add up past the supply
+		reward += t.fee
 	}
-	return nonce
-}
+	// once the supply is exhausted a block without fees has nothing to pay out
+	if reward > 0 {
+		transactions = append(transactions, NewCoinbaseTransaction(bc.blockchainAddress, reward, len(bc.chain)))
+	}
+	timestamp := bc.globals.NowUnixNano()
+	if median := medianTime(bc.chain); timestamp < median {
+		timestamp = median
+	}
+	template := NewBlock(0, previousHash, timestamp, bc.nextBits(bc.chain), transactions)
+	bc.cancelMining = cancel
+	bc.mux.Unlock()
+
+	nonce, err := bc.miner.Solve(ctx, template)
 
-func (bc *Blockchain) Mining() bool {
 	bc.mux.Lock()
 	defer bc.mux.Unlock()
+	bc.cancelMining = nil
+	if bc.LastBlock().Hash() != previousHash {
+		return nil, ErrStaleBlock
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := template
+	b.nonce = nonce
+	if err := bc.storage.AppendBlock(b); err != nil {
+		return nil, err
+	}
+	bc.extendChain(b)
+	bc.refreshTransactionPool()
+	bc.saveTransactionPool()
+	return b, nil
+}
 
-	if len(bc.transactionPool) > 0 {
-		bc.AddTransaction(MiningSender, bc.blockchainAddress, MiningReward, nil, nil)
-		bc.CreateBlock()
-		log.Println("action=mining status=success")
-		return true
-	} else {
+// interruptMining stops a search whose block can't extend the chain any more.
+// Callers must hold bc.mux.
+func (bc *Blockchain) interruptMining() {
+	if bc.cancelMining != nil {
+		bc.cancelMining()
+	}
+}
+
+// Mining creates a block from the transaction pool plus the miner's reward.
+// It does nothing while the pool is empty.
+func (bc *Blockchain) Mining() bool {
+	if bc.MempoolStats().Size == 0 {
 		log.Println("action=mining status=zero transactions to mine")
 		return false
 	}
+	b, err := bc.MineBlock(context.Background())
+	if err != nil {
+		log.Printf("ERROR: action=mining status=failure: %v", err)
+		return false
+	}
+	miner := bc.Miner()
+	log.Printf("action=mining status=success workers=%d hash_rate=%.0f", miner.Workers(), miner.HashRate())
+
+	// Neighbors may fetch our chain while handling the announcement, so it
+	// must go out after the lock is released.
+	bc.AnnounceBlock(b)
+	return true
 }
 
 func (bc *Blockchain) StartMining() {
@@ -245,19 +875,162 @@ func (bc *Blockchain) StartMining() {
 	_ = time.AfterFunc(time.Second*MiningTimerSec, bc.StartMining)
 }
 
-func (bc *Blockchain) CalculateTotalAmount(blockchainAddress string) float32 {
-	var totalAmount float32 = 0.0
-	for _, b := range bc.chain {
-		for _, t := range b.transactions {
-			value := t.value
-			if t.recipientBlockchainAddress == blockchainAddress {
-				totalAmount += value
-			}
+// CalculateTotalAmount returns the confirmed balance of an address.
+func (bc *Blockchain) CalculateTotalAmount(blockchainAddress string) types.Amount {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.balances[blockchainAddress]
+}
 
-			if t.senderBlockchainAddress == blockchainAddress {
-				totalAmount -= value
-			}
+// AppendBlock adds a block announced by a neighbor on top of the local chain.
+// It returns ErrKnownBlock for blocks already in the chain and
+// ErrUnknownParent when the block doesn't extend the current last block.
+func (bc *Blockchain) AppendBlock(b *Block) error {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	hash := b.Hash()
+	if _, ok := bc.blockIndex[hash]; ok {
+		return ErrKnownBlock
+	}
+	if b.previousHash != bc.LastBlock().Hash() {
+		return ErrUnknownParent
+	}
+	if err := bc.validBlock(b, bc.chain, bc.tipLedger()); err != nil {
+		return &ChainError{Height: len(bc.chain), Hash: hash, Err: err}
+	}
+	if err := bc.storage.AppendBlock(b); err != nil {
+		return err
+	}
+	bc.extendChain(b)
+	bc.interruptMining()
+	bc.refreshTransactionPool()
+	bc.saveTransactionPool()
+	log.Printf("action=append_block status=success height=%d", len(bc.chain)-1)
+	return nil
+}
+
+// AnnounceBlock pushes a block to every neighbor via POST /blocks.
+func (bc *Blockchain) AnnounceBlock(b *Block) {
+	m, _ := b.MarshalJSON()
+	bc.broadcast("/blocks", m)
+}
+
+func (bc *Blockchain) relayTransaction(t *Transaction) {
+	m, _ := t.MarshalJSON()
+	bc.broadcast("/transactions", m)
+}
+
+// broadcast posts body to path on every neighbor. Neighbors drop what they
+// already know, so a message doesn't bounce around forever.
+func (bc *Blockchain) broadcast(path string, body []byte) {
+	client := &http.Client{Timeout: time.Second * BlockchainRequestTimeoutSec}
+	for _, n := range bc.Neighbors() {
+		endpoint := fmt.Sprintf("http://%s%s", n, path)
+		resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("ERROR: broadcast to %s failed: %v", endpoint, err)
+			continue
+		}
+		_ = resp.Body.Close()
+		log.Printf("action=broadcast endpoint=%s status=%d", endpoint, resp.StatusCode)
+	}
+}
+
+// ResolveConflicts replaces the local chain with the valid chain holding the
+// most cumulative work among the neighbors' and reports whether a
+// replacement happened.
+func (bc *Blockchain) ResolveConflicts() bool {
+	var bestChain []*Block
+	bestWork := new(big.Int)
+	for _, n := range bc.Neighbors() {
+		chain, err := bc.fetchChain(n)
+		if err != nil {
+			log.Printf("ERROR: fetching chain from %s failed: %v", n, err)
+			continue
+		}
+		work := chainWork(chain)
+		if work.Cmp(bestWork) <= 0 {
+			continue
+		}
+		if err := bc.ValidChain(chain); err != nil {
+			log.Printf("ERROR: chain from %s rejected: %v", n, err)
+			continue
 		}
+		bestChain, bestWork = chain, work
+	}
+
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if bestChain == nil || bestWork.Cmp(bc.totalWork()) <= 0 {
+		log.Println("action=resolve_conflicts status=not_replaced")
+		return false
+	}
+	if err := bc.storage.ReplaceBlocks(bestChain); err != nil {
+		log.Printf("ERROR: storing the replacement chain failed: %v", err)
+		return false
+	}
+	bc.setChain(bestChain)
+	bc.interruptMining()
+	bc.refreshTransactionPool()
+	bc.saveTransactionPool()
+	log.Printf("action=resolve_conflicts status=replaced height=%d work=%s", len(bc.chain)-1, bestWork)
+	return true
+}
+
+func (bc *Blockchain) fetchChain(neighbor string) ([]*Block, error) {
+	client := &http.Client{Timeout: time.Second * BlockchainRequestTimeoutSec}
+	resp, err := client.Get(fmt.Sprintf("http://%s/", neighbor))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	remote := new(Blockchain)
+	if err := json.NewDecoder(resp.Body).Decode(remote); err != nil {
+		return nil, err
+	}
+	return remote.chain, nil
+}
+
+// refreshTransactionPool drops the pending transactions the chain now holds,
+// those whose nonces it has used up and those whose senders can no longer
+// pay for them after the chain changed, e.g. because a reorganization undid
+// the coins they spend. Where a sender can only cover some, the highest fee
+// rates stay. In UTXOMode that means transactions whose inputs aren't
+// unspent anymore. Mined coins only count once they have matured. Callers
+// must hold bc.mux.
+func (bc *Blockchain) refreshTransactionPool() {
+	bc.transactionPool.RemoveMined(func(id types.Byte32) bool {
+		_, mined := bc.transactionIndex[id]
+		return mined
+	})
+	immature := bc.genesis.immatureCoinbases(bc.chain)
+	immatureAmount, immatureOutput := immatureAmounts(immature), immatureOutPoints(immature)
+	spent := make(map[string]types.Amount)
+	n := bc.transactionPool.RemoveInvalid(func(t *Transaction) bool {
+		if t.nonce < bc.nonces[t.senderBlockchainAddress] {
+			return false
+		}
+		if bc.mode == UTXOMode {
+			return checkInputs(t, bc.unspentOutput, immatureOutput) == nil
+		}
+		cost, err := t.cost()
+		if err != nil {
+			return false
+		}
+		total, err := spent[t.senderBlockchainAddress].Add(cost)
+		if err != nil || total > bc.balances[t.senderBlockchainAddress]-immatureAmount[t.senderBlockchainAddress] {
+			return false
+		}
+		spent[t.senderBlockchainAddress] = total
+		return true
+	})
+	if n > 0 {
+		log.Printf("action=refresh_pool status=invalidated count=%d", n)
 	}
-	return totalAmount
 }
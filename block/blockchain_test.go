@@ -2,15 +2,29 @@ package block
 
 import (
 	types "blockchain/blockchaintypes"
+	"blockchain/globals"
 	"blockchain/mock_main"
 	"blockchain/wallet"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
+// mineBlock appends a block to bc even when the pool is empty, which is how
+// the tests pay rewards to the miner's address.
+func mineBlock(bc *Blockchain) bool {
+	_, err := bc.MineBlock(context.Background())
+	return err == nil
+}
+
 func TestBlockchain_CreateBlock(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -20,12 +34,18 @@ func TestBlockchain_CreateBlock(t *testing.T) {
 	gl.EXPECT().EmptyByte32().AnyTimes().Return(types.Byte32{})
 	gl.EXPECT().NowUnixNano().AnyTimes().Return(BlockTimestamp)
 
-	bc := NewBlockchain(gl)
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	walletMiner := wallet.NewWallet()
 	walletA := wallet.NewWallet()
 	walletB := wallet.NewWallet()
 
+	// walletA earns the first block reward so that it has something to send
+	bc.SetBlockchainAddress(walletA.BlockchainAddress())
+	mineBlock(bc)
 	bc.SetBlockchainAddress(walletMiner.BlockchainAddress())
 
 	Convey("blockchain initialized with root block", t, func() {
@@ -34,22 +54,26 @@ func TestBlockchain_CreateBlock(t *testing.T) {
 			walletA.PublicKey(),
 			walletA.BlockchainAddress(),
 			walletB.BlockchainAddress(),
-			1.0,
+			types.Coin,
+			0,
+			0,
 		)
 
 		t1Signature := t1.GenerateSignature()
 
-		isAdded := bc.AddTransaction(
+		err := bc.AddTransaction(
 			walletA.BlockchainAddress(),
 			walletB.BlockchainAddress(),
-			1.0,
+			types.Coin,
+			0,
+			0,
 			walletA.PublicKey(),
 			t1Signature,
 		)
 
-		So(isAdded, ShouldBeTrue)
+		So(err, ShouldBeNil)
 
-		bc.Mining()
+		mineBlock(bc)
 
 		// fmt.Printf("%v", t)
 		fmt.Printf("signature %s\n", t1.GenerateSignature())
@@ -88,3 +112,429 @@ func TestBlockchain_CreateBlock(t *testing.T) {
 	// })
 	bc.Print()
 }
+
+func TestBlockchain_Mining(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	g := testGenesis()
+	g.Allocations = []Allocation{{Address: walletA.BlockchainAddress(), Amount: 10 * types.Coin}}
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.SetBlockchainAddress(walletB.BlockchainAddress())
+
+	Convey("nothing is mined while the pool is empty", t, func() {
+		So(bc.Mining(), ShouldBeFalse)
+		So(bc.chain, ShouldHaveLength, 1)
+	})
+
+	Convey("pending transactions are mined with the reward", t, func() {
+		So(addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin), ShouldBeNil)
+		So(bc.Mining(), ShouldBeTrue)
+		So(bc.chain, ShouldHaveLength, 2)
+		So(bc.chain[1].transactions, ShouldHaveLength, 2)
+		So(bc.TransactionPool(), ShouldBeEmpty)
+		So(bc.Mining(), ShouldBeFalse)
+	})
+}
+
+func TestBlockchain_ResolveConflicts(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	bcA, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcB, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+
+	// both nodes share the block that funds walletA
+	bcA.SetBlockchainAddress(walletA.BlockchainAddress())
+	mineBlock(bcA)
+	if err := bcB.AppendBlock(bcA.LastBlock()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same signed transaction is pending on both nodes, but only A mines it
+	tx := wallet.NewTransaction(walletA.PrivateKey(), walletA.PublicKey(), walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin, 0, 0)
+	signature := tx.GenerateSignature()
+	for _, bc := range []*Blockchain{bcA, bcB} {
+		bc.AddTransaction(walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin, 0, 0, walletA.PublicKey(), signature)
+	}
+	mineBlock(bcA)
+	addSignedTransaction(bcA, walletB, walletA.BlockchainAddress(), types.Coin/2)
+	mineBlock(bcA)
+
+	served := bcA
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		m, _ := served.MarshalJSON()
+		w.Write(m)
+	}))
+	defer server.Close()
+	bcB.neighbors = []string{server.Listener.Addr().String()}
+
+	Convey("a longer invalid chain is ignored", t, func() {
+		So(len(bcB.chain), ShouldEqual, 2)
+		forged, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		forged.chain = append(forged.chain, bcA.chain[1:]...)
+		forged.chain = append(forged.chain, NewBlock(0, types.Byte32{1}, BlockTimestamp, bcA.LastBlock().bits, nil))
+		served = forged
+
+		So(bcB.ResolveConflicts(), ShouldBeFalse)
+		So(len(bcB.chain), ShouldEqual, 2)
+		So(bcB.transactionPool.Len(), ShouldEqual, 1)
+	})
+
+	Convey("a longer valid chain replaces the local one", t, func() {
+		served = bcA
+
+		So(bcB.ResolveConflicts(), ShouldBeTrue)
+		So(len(bcB.chain), ShouldEqual, len(bcA.chain))
+		So(bcB.LastBlock().Hash(), ShouldEqual, bcA.LastBlock().Hash())
+		So(bcB.ValidChain(bcB.chain), ShouldBeNil)
+
+		Convey("and confirmed transactions leave the pool", func() {
+			So(bcB.transactionPool.Len(), ShouldEqual, 0)
+		})
+	})
+
+	Convey("a chain of equal length is not adopted", t, func() {
+		So(bcB.ResolveConflicts(), ShouldBeFalse)
+	})
+}
+
+func TestBlockchain_UnmarshalJSON(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	bc.SetBlockchainAddress(walletA.BlockchainAddress())
+	mineBlock(bc)
+	addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin*5/4)
+	mineBlock(bc)
+
+	Convey("a mined chain decodes into an identical, still valid chain", t, func() {
+		m, err := json.Marshal(bc)
+		So(err, ShouldBeNil)
+
+		decoded := new(Blockchain)
+		So(json.Unmarshal(m, decoded), ShouldBeNil)
+		So(len(decoded.chain), ShouldEqual, len(bc.chain))
+		for i := range bc.chain {
+			So(decoded.chain[i].Hash(), ShouldEqual, bc.chain[i].Hash())
+		}
+		So(bc.ValidChain(decoded.chain), ShouldBeNil)
+
+		again, _ := json.Marshal(decoded)
+		So(string(again), ShouldEqual, string(m))
+	})
+
+	Convey("a document without blocks is rejected", t, func() {
+		So(json.Unmarshal([]byte(`{}`), new(Blockchain)), ShouldNotBeNil)
+		So(json.Unmarshal([]byte(`{"blocks":[null]}`), new(Blockchain)), ShouldNotBeNil)
+	})
+}
+
+func TestBlockchain_AddTransaction(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	bc.SetBlockchainAddress(walletA.BlockchainAddress())
+	mineBlock(bc)
+
+	Convey("a sender can spend its confirmed balance", t, func() {
+		So(addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin*3/4), ShouldBeNil)
+
+		Convey("but not what is already pending", func() {
+			err := addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin/2)
+			So(errors.Is(err, ErrInsufficientBalance), ShouldBeTrue)
+		})
+	})
+
+	Convey("an address without coins can't send any", t, func() {
+		err := addSignedTransaction(bc, walletB, walletA.BlockchainAddress(), types.Coin/10)
+		So(errors.Is(err, ErrInsufficientBalance), ShouldBeTrue)
+	})
+
+	Convey("an identical signed transfer is only accepted once", t, func() {
+		nonce := bc.Nonce(walletA.BlockchainAddress()).Nonce
+		tx := wallet.NewTransaction(walletA.PrivateKey(), walletA.PublicKey(), walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/10, 0, nonce)
+		signature := tx.GenerateSignature()
+		So(bc.AddTransaction(walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/10, 0, nonce, walletA.PublicKey(), signature), ShouldBeNil)
+
+		err := bc.AddTransaction(walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/10, 0, nonce, walletA.PublicKey(), signature)
+		So(errors.Is(err, ErrDuplicateTransaction), ShouldBeTrue)
+
+		Convey("even after it has been mined", func() {
+			mineBlock(bc)
+			err := bc.AddTransaction(walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/10, 0, nonce, walletA.PublicKey(), signature)
+			So(errors.Is(err, ErrDuplicateTransaction), ShouldBeTrue)
+
+			// (r, n-s) verifies just like (r, s) but must not pass for a new transfer
+			malleated := &globals.Signature{R: signature.R, S: new(big.Int).Sub(walletA.PublicKey().Params().N, signature.S)}
+			So(bc.VerifyTransactionSignature(walletA.PublicKey(), malleated, NewSignedTransaction(walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/10, 0, nonce, nil, nil)), ShouldBeTrue)
+			err = bc.AddTransaction(walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/10, 0, nonce, walletA.PublicKey(), malleated)
+			So(errors.Is(err, ErrDuplicateTransaction), ShouldBeTrue)
+		})
+	})
+
+	Convey("malformed transfers are rejected with a reason", t, func() {
+		So(errors.Is(addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), -1), ErrInvalidValue), ShouldBeTrue)
+
+		err := bc.AddTransaction(MiningSender, walletB.BlockchainAddress(), MiningReward, 0, 0, nil, nil)
+		So(errors.Is(err, ErrReservedSender), ShouldBeTrue)
+
+		nonce := bc.Nonce(walletA.BlockchainAddress()).Nonce
+		tx := wallet.NewTransaction(walletA.PrivateKey(), walletA.PublicKey(), walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/10, 0, nonce)
+		err = bc.AddTransaction(walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/5, 0, nonce, walletA.PublicKey(), tx.GenerateSignature())
+		So(errors.Is(err, ErrInvalidSignature), ShouldBeTrue)
+	})
+
+	Convey("nobody can spend from an address with a key of their own", t, func() {
+		thief := wallet.NewWallet()
+		balance := bc.CalculateTotalAmount(walletA.BlockchainAddress())
+		nonce := bc.Nonce(walletA.BlockchainAddress()).Nonce
+		// a valid signature, but by a key walletA's address wasn't derived from
+		tx := wallet.NewTransaction(thief.PrivateKey(), thief.PublicKey(), walletA.BlockchainAddress(), thief.BlockchainAddress(), types.Coin/10, 0, nonce)
+		signature := tx.GenerateSignature()
+		So(bc.VerifyTransactionSignature(thief.PublicKey(), signature, NewSignedTransaction(walletA.BlockchainAddress(), thief.BlockchainAddress(), types.Coin/10, 0, nonce, nil, nil)), ShouldBeTrue)
+
+		err := bc.AddTransaction(walletA.BlockchainAddress(), thief.BlockchainAddress(), types.Coin/10, 0, nonce, thief.PublicKey(), signature)
+		So(errors.Is(err, ErrSenderMismatch), ShouldBeTrue)
+		mineBlock(bc)
+		So(bc.CalculateTotalAmount(thief.BlockchainAddress()), ShouldEqual, 0)
+		So(bc.CalculateTotalAmount(walletA.BlockchainAddress()), ShouldBeGreaterThanOrEqualTo, balance)
+	})
+}
+
+func TestBlockchain_TransactionStatus(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	bc.SetBlockchainAddress(walletA.BlockchainAddress())
+	mineBlock(bc)
+
+	id := NewTransaction(walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/4).ID()
+
+	Convey("an unknown transaction is not found", t, func() {
+		_, ok := bc.TransactionStatus(id)
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("a submitted transaction is pending", t, func() {
+		So(addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin/4), ShouldBeNil)
+		status, ok := bc.TransactionStatus(id)
+		So(ok, ShouldBeTrue)
+		So(status.Status, ShouldEqual, TransactionPending)
+		So(status.BlockHeight, ShouldBeNil)
+		So(status.Confirmations, ShouldEqual, 0)
+
+		Convey("until it is mined, then every block confirms it further", func() {
+			mineBlock(bc)
+			status, ok := bc.TransactionStatus(id)
+			So(ok, ShouldBeTrue)
+			So(status.Status, ShouldEqual, TransactionConfirmed)
+			So(*status.BlockHeight, ShouldEqual, 2)
+			So(status.Confirmations, ShouldEqual, 1)
+			So(status.ID, ShouldEqual, fmt.Sprintf("%x", id))
+
+			mineBlock(bc)
+			status, _ = bc.TransactionStatus(id)
+			So(status.Confirmations, ShouldEqual, 2)
+		})
+	})
+}
+
+func TestBlockchain_BlockLookup(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		mineBlock(bc)
+	}
+
+	Convey("blocks are found by height and by hash", t, func() {
+		So(bc.Height(), ShouldEqual, 4)
+		for height, b := range bc.chain {
+			byHeight, ok := bc.BlockByHeight(height)
+			So(ok, ShouldBeTrue)
+			So(byHeight.Block, ShouldEqual, b)
+			So(byHeight.Hash, ShouldEqual, fmt.Sprintf("%x", b.Hash()))
+
+			byHash, ok := bc.BlockByHash(b.Hash())
+			So(ok, ShouldBeTrue)
+			So(byHash.Height, ShouldEqual, height)
+			So(byHash.TransactionCount, ShouldEqual, len(b.transactions))
+		}
+		So(bc.LatestBlock().Height, ShouldEqual, 4)
+
+		_, ok := bc.BlockByHeight(5)
+		So(ok, ShouldBeFalse)
+		_, ok = bc.BlockByHash(types.Byte32{1})
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("pages stop at the end of the chain", t, func() {
+		So(len(bc.Blocks(0, 2)), ShouldEqual, 2)
+		So(bc.Blocks(3, 10)[0].Height, ShouldEqual, 3)
+		So(len(bc.Blocks(3, 10)), ShouldEqual, 2)
+		So(bc.Blocks(5, 10), ShouldBeEmpty)
+	})
+
+	Convey("the indexes follow a chain replacement", t, func() {
+		stale := bc.LastBlock().Hash()
+		bc.mux.Lock()
+		bc.setChain(bc.chain[:3])
+		bc.mux.Unlock()
+
+		_, ok := bc.BlockByHash(stale)
+		So(ok, ShouldBeFalse)
+		So(bc.LatestBlock().Height, ShouldEqual, 2)
+	})
+}
+
+func TestBlockchain_Fees(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	walletMiner := wallet.NewWallet()
+	g := testGenesis()
+	g.Allocations = []Allocation{{Address: walletA.BlockchainAddress(), Amount: 10 * types.Coin}}
+	fee := types.Coin / 100
+
+	Convey("the pool is ordered by fee rate and the miner collects the fees", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		bc.SetBlockchainAddress(walletMiner.BlockchainAddress())
+		So(addSignedTransactionWithFee(bc, walletA, walletB.BlockchainAddress(), types.Coin, fee), ShouldBeNil)
+		So(addSignedTransactionWithFee(bc, walletA, walletB.BlockchainAddress(), types.Coin, 3*fee), ShouldBeNil)
+		So(addSignedTransactionWithFee(bc, walletA, walletB.BlockchainAddress(), types.Coin, 2*fee), ShouldBeNil)
+		So(addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin), ShouldBeNil)
+
+		pool := bc.TransactionPool()
+		So(len(pool), ShouldEqual, 4)
+		for i, want := range []types.Amount{3 * fee, 2 * fee, fee, 0} {
+			So(pool[i].Fee(), ShouldEqual, want)
+		}
+
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.ValidChain(bc.chain), ShouldBeNil)
+		So(bc.CalculateTotalAmount(walletMiner.BlockchainAddress()), ShouldEqual, MiningReward+6*fee)
+		So(bc.CalculateTotalAmount(walletA.BlockchainAddress()), ShouldEqual, 6*types.Coin-6*fee)
+		So(bc.CalculateTotalAmount(walletB.BlockchainAddress()), ShouldEqual, 4*types.Coin)
+	})
+
+	Convey("the sender has to cover value and fee", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		err = addSignedTransactionWithFee(bc, walletA, walletB.BlockchainAddress(), 10*types.Coin, fee)
+		So(errors.Is(err, ErrInsufficientBalance), ShouldBeTrue)
+		err = addSignedTransactionWithFee(bc, walletA, walletB.BlockchainAddress(), types.Coin, -fee)
+		So(errors.Is(err, ErrInvalidFee), ShouldBeTrue)
+	})
+
+	Convey("a block takes at most MaxBlockTransactions, highest fee rates first", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		bc.SetMempoolConfig(MempoolConfig{MaxPerSender: 2 * MaxBlockTransactions})
+		for i := 0; i < MaxBlockTransactions; i++ {
+			So(addSignedTransactionWithFee(bc, walletA, walletB.BlockchainAddress(), types.Amount(i+1), fee), ShouldBeNil)
+		}
+		So(addSignedTransactionWithFee(bc, walletA, walletB.BlockchainAddress(), types.Coin, 0), ShouldBeNil)
+
+		So(mineBlock(bc), ShouldBeTrue)
+		So(len(bc.LastBlock().transactions), ShouldEqual, MaxBlockTransactions)
+		pool := bc.TransactionPool()
+		So(len(pool), ShouldEqual, 2)
+		So(pool[1].Fee(), ShouldEqual, 0)
+	})
+}
+
+func TestBlockchain_Nonces(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	funded := func() *Blockchain {
+		g := testGenesis()
+		g.Allocations = []Allocation{{Address: walletA.BlockchainAddress(), Amount: 10 * types.Coin}}
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bc
+	}
+
+	Convey("a used nonce can't be spent again", t, func() {
+		bc := funded()
+		So(addSignedTransactionWithNonce(bc, walletA, walletB.BlockchainAddress(), types.Coin, 0, 0), ShouldBeNil)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.Nonce(walletA.BlockchainAddress()).ConfirmedNonce, ShouldEqual, 1)
+
+		err := addSignedTransactionWithNonce(bc, walletA, walletB.BlockchainAddress(), 2*types.Coin, 0, 0)
+		So(errors.Is(err, ErrNonceTooLow), ShouldBeTrue)
+
+		So(addSignedTransactionWithNonce(bc, walletA, walletB.BlockchainAddress(), 2*types.Coin, 0, 1), ShouldBeNil)
+		err = addSignedTransactionWithNonce(bc, walletA, walletB.BlockchainAddress(), 3*types.Coin, 0, 1)
+		So(errors.Is(err, ErrNonceInUse), ShouldBeTrue)
+	})
+
+	Convey("a nonce after a gap waits for the gap to be filled", t, func() {
+		bc := funded()
+		So(addSignedTransactionWithNonce(bc, walletA, walletB.BlockchainAddress(), types.Coin, 1, 1), ShouldBeNil)
+		So(bc.Nonce(walletA.BlockchainAddress()).Nonce, ShouldEqual, 0)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.LastBlock().transactions, ShouldHaveLength, 1)
+		So(bc.TransactionPool(), ShouldHaveLength, 1)
+
+		So(addSignedTransactionWithNonce(bc, walletA, walletB.BlockchainAddress(), types.Coin, 0, 0), ShouldBeNil)
+		So(bc.Nonce(walletA.BlockchainAddress()).Nonce, ShouldEqual, 2)
+		So(mineBlock(bc), ShouldBeTrue)
+		mined := bc.LastBlock().transactions
+		So(mined, ShouldHaveLength, 3)
+		So(mined[0].Nonce(), ShouldEqual, 0)
+		So(mined[1].Nonce(), ShouldEqual, 1)
+		So(bc.ValidChain(bc.chain), ShouldBeNil)
+		So(bc.Nonce(walletA.BlockchainAddress()), ShouldResemble, &NonceResponse{
+			Address:        walletA.BlockchainAddress(),
+			Nonce:          2,
+			ConfirmedNonce: 2,
+		})
+	})
+
+	Convey("coinbase transactions get ids of their own", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		bc.SetBlockchainAddress(walletA.BlockchainAddress())
+		So(mineBlock(bc), ShouldBeTrue)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.chain[1].transactions[0].ID(), ShouldNotEqual, bc.chain[2].transactions[0].ID())
+		So(bc.chain[2].transactions[0].Nonce(), ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,207 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AddressHistoryPageSize is the number of confirmed transactions returned per
+// page of an address history.
+const AddressHistoryPageSize = 20
+
+// transactionRef locates a mined transaction by block height and position.
+type transactionRef struct {
+	height int
+	index  int
+}
+
+func (r transactionRef) less(o transactionRef) bool {
+	return r.height < o.height || (r.height == o.height && r.index < o.index)
+}
+
+// AddressHistory is one page of the transactions that moved coins in or out
+// of an address. Pending transactions are only listed on the first page.
+// NextCursor is empty on the last page.
+type AddressHistory struct {
+	Address      string               `json:"address"`
+	Balance      types.Amount         `json:"balance"`
+	Transactions []*TransactionStatus `json:"transactions"`
+	NextCursor   string               `json:"next_cursor,omitempty"`
+}
+
+// totalWork is the cumulative work of the chain. Callers must hold bc.mux.
+func (bc *Blockchain) totalWork() *big.Int {
+	return new(big.Int).Set(bc.chainWork[len(bc.chainWork)-1])
+}
+
+// setChain swaps in chain. The indexes are rolled back to the blocks both
+// chains share and extended from there. Callers must hold bc.mux.
+func (bc *Blockchain) setChain(chain []*Block) {
+	common := 0
+	for common < len(chain) && common < len(bc.chain) {
+		if height, ok := bc.blockIndex[chain[common].Hash()]; !ok || height != common {
+			break
+		}
+		common++
+	}
+	bc.truncateChain(common)
+	for _, b := range chain[common:] {
+		bc.extendChain(b)
+	}
+}
+
+// extendChain appends b to the chain and indexes it. Callers must hold bc.mux.
+func (bc *Blockchain) extendChain(b *Block) {
+	height := len(bc.chain)
+	bc.chain = append(bc.chain, b)
+	bc.blockIndex[b.Hash()] = height
+	work := Work(b.bits)
+	if height > 0 {
+		work.Add(work, bc.chainWork[height-1])
+	}
+	bc.chainWork = append(bc.chainWork, work)
+	supply := issuance(b)
+	if height > 0 {
+		supply += bc.chainSupply[height-1]
+	}
+	bc.chainSupply = append(bc.chainSupply, supply)
+	for i, t := range b.transactions {
+		bc.transactionIndex[t.ID()] = height
+		// blocks are validated before they get here, so nothing overflows
+		bc.balances[t.senderBlockchainAddress] -= t.value + t.fee
+		for _, o := range t.Outputs() {
+			bc.balances[o.Address] += o.Value
+		}
+		if t.senderBlockchainAddress != MiningSender {
+			bc.nonces[t.senderBlockchainAddress] = t.nonce + 1
+		}
+		if bc.mode == UTXOMode {
+			bc.connectOutputs(t)
+		}
+		ref := transactionRef{height: height, index: i}
+		for _, address := range t.addresses() {
+			bc.addressIndex[address] = append(bc.addressIndex[address], ref)
+		}
+	}
+}
+
+// truncateChain drops every block from height on and undoes what
+// extendChain recorded for them. Callers must hold bc.mux.
+func (bc *Blockchain) truncateChain(height int) {
+	for len(bc.chain) > height {
+		top := len(bc.chain) - 1
+		b := bc.chain[top]
+		for i := len(b.transactions) - 1; i >= 0; i-- {
+			t := b.transactions[i]
+			if bc.mode == UTXOMode {
+				bc.disconnectOutputs(t)
+			}
+			delete(bc.transactionIndex, t.ID())
+			if t.senderBlockchainAddress != MiningSender {
+				if t.nonce > 0 {
+					bc.nonces[t.senderBlockchainAddress] = t.nonce
+				} else {
+					delete(bc.nonces, t.senderBlockchainAddress)
+				}
+			}
+			bc.unrecordBalance(t.senderBlockchainAddress, t.value+t.fee)
+			for _, o := range t.Outputs() {
+				bc.unrecordBalance(o.Address, -o.Value)
+			}
+			for _, address := range t.addresses() {
+				bc.popAddressRef(address)
+			}
+		}
+		delete(bc.blockIndex, b.Hash())
+		bc.chain = bc.chain[:top]
+		bc.chainWork = bc.chainWork[:top]
+		bc.chainSupply = bc.chainSupply[:top]
+	}
+}
+
+func (bc *Blockchain) unrecordBalance(address string, delta types.Amount) {
+	if balance := bc.balances[address] + delta; balance != 0 {
+		bc.balances[address] = balance
+	} else {
+		delete(bc.balances, address)
+	}
+}
+
+func (bc *Blockchain) popAddressRef(address string) {
+	if refs := bc.addressIndex[address]; len(refs) > 1 {
+		bc.addressIndex[address] = refs[:len(refs)-1]
+	} else {
+		delete(bc.addressIndex, address)
+	}
+}
+
+// AddressHistory lists the transactions of an address, newest first. An
+// empty cursor starts at the pending transactions; NextCursor of a page
+// continues where it ended.
+func (bc *Blockchain) AddressHistory(address string, cursor string) (*AddressHistory, error) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	refs := bc.addressIndex[address]
+	end := len(refs)
+	h := &AddressHistory{
+		Address:      address,
+		Balance:      bc.balances[address],
+		Transactions: []*TransactionStatus{},
+	}
+	if cursor == "" {
+		for _, t := range bc.transactionPool.Transactions() {
+			if t.involves(address) {
+				h.Transactions = append(h.Transactions, &TransactionStatus{
+					ID:          fmt.Sprintf("%x", t.ID()),
+					Status:      TransactionPending,
+					Transaction: t,
+				})
+			}
+		}
+	} else {
+		after, err := parseCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		end = sort.Search(len(refs), func(i int) bool { return !refs[i].less(after) })
+	}
+
+	start := end - AddressHistoryPageSize
+	if start < 0 {
+		start = 0
+	}
+	for i := end - 1; i >= start; i-- {
+		ref := refs[i]
+		t := bc.chain[ref.height].transactions[ref.index]
+		height := ref.height
+		h.Transactions = append(h.Transactions, &TransactionStatus{
+			ID:            fmt.Sprintf("%x", t.ID()),
+			Status:        TransactionConfirmed,
+			BlockHeight:   &height,
+			Confirmations: len(bc.chain) - ref.height,
+			Transaction:   t,
+		})
+	}
+	if start > 0 {
+		h.NextCursor = fmt.Sprintf("%d-%d", refs[start].height, refs[start].index)
+	}
+	return h, nil
+}
+
+// parseCursor reads the height-index pair written to NextCursor.
+func parseCursor(cursor string) (transactionRef, error) {
+	height, index, ok := strings.Cut(cursor, "-")
+	if ok {
+		h, errH := strconv.Atoi(height)
+		i, errI := strconv.Atoi(index)
+		if errH == nil && errI == nil && h >= 0 && i >= 0 {
+			return transactionRef{height: h, index: i}, nil
+		}
+	}
+	return transactionRef{}, fmt.Errorf("malformed cursor %q", cursor)
+}
@@ -0,0 +1,123 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/wallet"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBlockchain_AddressIndex(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	bc.SetBlockchainAddress(walletA.BlockchainAddress())
+	mineBlock(bc)
+	mineBlock(bc)
+	addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin/2)
+	mineBlock(bc)
+	addSignedTransaction(bc, walletB, walletB.BlockchainAddress(), types.Coin/4)
+	mineBlock(bc)
+
+	Convey("indexed balances match a recount of the chain", t, func() {
+		l := ledgerOf(bc.chain, bc.mode)
+		for _, w := range []*wallet.Wallet{walletA, walletB} {
+			So(bc.CalculateTotalAmount(w.BlockchainAddress()), ShouldEqual, l.balances[w.BlockchainAddress()])
+		}
+		So(bc.CalculateTotalAmount(walletB.BlockchainAddress()), ShouldEqual, types.Coin/2)
+	})
+
+	Convey("the tip ledger reads the indexes without writing to them", t, func() {
+		bc.mux.Lock()
+		defer bc.mux.Unlock()
+		recount, tip := ledgerOf(bc.chain, bc.mode), bc.tipLedger()
+		So(tip.supply, ShouldEqual, recount.supply)
+		for _, w := range []*wallet.Wallet{walletA, walletB} {
+			So(tip.balance(w.BlockchainAddress()), ShouldEqual, recount.balances[w.BlockchainAddress()])
+			So(tip.nonce(w.BlockchainAddress()), ShouldEqual, recount.nonces[w.BlockchainAddress()])
+		}
+		mined := bc.chain[3].transactions[0]
+		So(tip.hasTransaction(mined.ID()), ShouldBeTrue)
+
+		again := NewTransaction(walletB.BlockchainAddress(), walletA.BlockchainAddress(), types.Coin/8)
+		again.nonce = 1
+		So(tip.record(again), ShouldBeNil)
+		So(tip.balance(walletA.BlockchainAddress()), ShouldEqual, recount.balances[walletA.BlockchainAddress()]+types.Coin/8)
+		So(tip.nonce(walletB.BlockchainAddress()), ShouldEqual, 2)
+		So(bc.balances[walletA.BlockchainAddress()], ShouldEqual, recount.balances[walletA.BlockchainAddress()])
+		So(bc.nonces[walletB.BlockchainAddress()], ShouldEqual, 1)
+	})
+
+	Convey("a rolled back chain leaves the indexes as if it had never grown", t, func() {
+		full := append([]*Block(nil), bc.chain...)
+		bc.mux.Lock()
+		bc.setChain(full[:2])
+		bc.mux.Unlock()
+
+		So(bc.CalculateTotalAmount(walletA.BlockchainAddress()), ShouldEqual, MiningReward)
+		So(bc.CalculateTotalAmount(walletB.BlockchainAddress()), ShouldEqual, 0)
+		So(bc.addressIndex[walletB.BlockchainAddress()], ShouldBeEmpty)
+		_, ok := bc.BlockByHash(full[3].Hash())
+		So(ok, ShouldBeFalse)
+
+		bc.mux.Lock()
+		bc.setChain(full)
+		bc.mux.Unlock()
+		So(bc.CalculateTotalAmount(walletB.BlockchainAddress()), ShouldEqual, types.Coin/2)
+		So(len(bc.addressIndex[walletB.BlockchainAddress()]), ShouldEqual, 2)
+	})
+}
+
+func TestBlockchain_AddressHistory(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	bc.SetBlockchainAddress(walletA.BlockchainAddress())
+	for i := 0; i < AddressHistoryPageSize+5; i++ {
+		mineBlock(bc)
+	}
+	addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin/2)
+
+	Convey("the first page starts with pending transactions, newest first", t, func() {
+		h, err := bc.AddressHistory(walletA.BlockchainAddress(), "")
+		So(err, ShouldBeNil)
+		So(h.Balance, ShouldEqual, types.Amount(AddressHistoryPageSize+5)*MiningReward)
+		So(len(h.Transactions), ShouldEqual, AddressHistoryPageSize+1)
+		So(h.Transactions[0].Status, ShouldEqual, TransactionPending)
+		So(*h.Transactions[1].BlockHeight, ShouldEqual, AddressHistoryPageSize+5)
+		So(*h.Transactions[AddressHistoryPageSize].BlockHeight, ShouldEqual, 6)
+		So(h.NextCursor, ShouldNotBeEmpty)
+
+		Convey("and the cursor continues with older ones", func() {
+			next, err := bc.AddressHistory(walletA.BlockchainAddress(), h.NextCursor)
+			So(err, ShouldBeNil)
+			So(len(next.Transactions), ShouldEqual, 5)
+			So(*next.Transactions[0].BlockHeight, ShouldEqual, 5)
+			So(*next.Transactions[4].BlockHeight, ShouldEqual, 1)
+			So(next.NextCursor, ShouldBeEmpty)
+		})
+	})
+
+	Convey("an address only sees its own transactions", t, func() {
+		h, err := bc.AddressHistory(walletB.BlockchainAddress(), "")
+		So(err, ShouldBeNil)
+		So(len(h.Transactions), ShouldEqual, 1)
+		So(h.Balance, ShouldEqual, 0)
+	})
+
+	Convey("a malformed cursor is rejected", t, func() {
+		_, err := bc.AddressHistory(walletA.BlockchainAddress(), "later")
+		So(err, ShouldNotBeNil)
+	})
+}
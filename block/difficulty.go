@@ -0,0 +1,154 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"math/big"
+	"sort"
+	"time"
+)
+
+const (
+	// MedianTimeSpan is the number of preceding blocks whose median
+	// timestamp a new block must not fall behind.
+	MedianTimeSpan = 11
+	// MaxFutureBlockTime is how far a block's timestamp may run ahead of the
+	// local clock.
+	MaxFutureBlockTime = 2 * time.Hour
+	// MaxRetargetFactor bounds how much a single retarget can change the
+	// target in either direction.
+	MaxRetargetFactor = 4
+	// MaxDifficulty is the most leading zero hex digits a genesis may ask
+	// for. One more would leave a target of zero, which no hash meets.
+	MaxDifficulty = 2*len(types.Byte32{}) - 1
+)
+
+// maxTarget is the easiest target there is: a hash with one leading zero hex
+// digit.
+var maxTarget = difficultyTarget(1)
+
+// CompactToTarget expands a compact target: the top byte is the length of the
+// target in bytes, the lower three bytes its most significant digits.
+func CompactToTarget(bits uint32) *big.Int {
+	exponent := uint(bits >> 24)
+	target := big.NewInt(int64(bits & 0x007fffff))
+	if exponent <= 3 {
+		return target.Rsh(target, 8*(3-exponent))
+	}
+	return target.Lsh(target, 8*(exponent-3))
+}
+
+// TargetToCompact encodes target in the compact form, dropping everything
+// past its three most significant bytes.
+func TargetToCompact(target *big.Int) uint32 {
+	exponent := uint((target.BitLen() + 7) / 8)
+	var mantissa uint64
+	if exponent <= 3 {
+		mantissa = target.Uint64() << (8 * (3 - exponent))
+	} else {
+		mantissa = new(big.Int).Rsh(target, 8*(exponent-3)).Uint64()
+	}
+	// the top mantissa bit would read as a sign, move it into the exponent
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+	return uint32(exponent)<<24 | uint32(mantissa)
+}
+
+// difficultyTarget is the target met by hashes with zeros leading zero hex
+// digits.
+func difficultyTarget(zeros int) *big.Int {
+	target := new(big.Int).Lsh(big.NewInt(1), uint(256-4*zeros))
+	return target.Sub(target, big.NewInt(1))
+}
+
+// Work is the expected number of hashes needed to meet the target of bits.
+func Work(bits uint32) *big.Int {
+	target := CompactToTarget(bits)
+	if target.Sign() <= 0 {
+		return new(big.Int)
+	}
+	work := new(big.Int).Lsh(big.NewInt(1), 256)
+	return work.Div(work, target.Add(target, big.NewInt(1)))
+}
+
+// chainWork sums the work of every block in chain.
+func chainWork(chain []*Block) *big.Int {
+	work := new(big.Int)
+	for _, b := range chain {
+		work.Add(work, Work(b.bits))
+	}
+	return work
+}
+
+// headerAt reads the timestamp and bits of the block at height, so that the
+// rules below apply to full blocks and bare headers alike.
+type headerAt func(height int) (timestamp int64, bits uint32)
+
+func blocksAt(chain []*Block) headerAt {
+	return func(height int) (int64, uint32) {
+		return chain[height].timestamp, chain[height].bits
+	}
+}
+
+// nextBits is the target a block extending chain must declare.
+func (bc *Blockchain) nextBits(chain []*Block) uint32 {
+	return retarget(bc.genesis, len(chain), blocksAt(chain))
+}
+
+// retarget is the target of the block at height, read off the blocks below
+// it. It changes every RetargetInterval blocks, scaled by how long the last
+// interval took compared with the genesis block time. No window starts at the
+// genesis block, whose timestamp comes from the spec rather than a miner, so
+// the first change comes at height 2*RetargetInterval (3 for an interval of
+// 1) and every block below it keeps the genesis target.
+func retarget(g *Genesis, height int, at headerAt) uint32 {
+	lastTimestamp, lastBits := at(height - 1)
+	interval := g.RetargetInterval
+	if height%interval != 0 || height-interval-1 < 1 {
+		return lastBits
+	}
+	firstTimestamp, _ := at(height - interval - 1)
+
+	expected := int64(interval) * int64(g.BlockTime) * int64(time.Second)
+	actual := lastTimestamp - firstTimestamp
+	if actual < expected/MaxRetargetFactor {
+		actual = expected / MaxRetargetFactor
+	}
+	if actual > expected*MaxRetargetFactor {
+		actual = expected * MaxRetargetFactor
+	}
+
+	target := CompactToTarget(lastBits)
+	target.Mul(target, big.NewInt(actual))
+	target.Div(target, big.NewInt(expected))
+	if target.Cmp(maxTarget) > 0 {
+		target = maxTarget
+	}
+	if target.Sign() <= 0 {
+		target = big.NewInt(1)
+	}
+	return TargetToCompact(target)
+}
+
+// medianTime is the median timestamp of the last MedianTimeSpan blocks of
+// chain.
+func medianTime(chain []*Block) int64 {
+	return medianTimeBelow(len(chain), blocksAt(chain))
+}
+
+// medianTimeBelow is the median timestamp of the last MedianTimeSpan blocks
+// below height.
+func medianTimeBelow(height int, at headerAt) int64 {
+	from := height - MedianTimeSpan
+	if from < 0 {
+		from = 0
+	}
+	timestamps := make([]int64, 0, height-from)
+	for h := from; h < height; h++ {
+		timestamp, _ := at(h)
+		timestamps = append(timestamps, timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
+}
@@ -0,0 +1,174 @@
+package block
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDifficulty_Compact(t *testing.T) {
+	Convey("compact targets expand as in Bitcoin", t, func() {
+		want, _ := new(big.Int).SetString("00000000ffff0000000000000000000000000000000000000000000000000000", 16)
+		So(CompactToTarget(0x1d00ffff).Cmp(want), ShouldEqual, 0)
+		So(TargetToCompact(want), ShouldEqual, uint32(0x1d00ffff))
+		So(CompactToTarget(0x03123456).Int64(), ShouldEqual, 0x123456)
+		So(CompactToTarget(0x02123400).Int64(), ShouldEqual, 0x1234)
+	})
+
+	Convey("a mantissa with its top bit set moves into the exponent", t, func() {
+		So(TargetToCompact(big.NewInt(0x80)), ShouldEqual, uint32(0x02008000))
+		So(CompactToTarget(0x02008000).Int64(), ShouldEqual, 0x80)
+	})
+
+	Convey("leading zero hex digits map to targets that hashes can be compared to", t, func() {
+		target := CompactToTarget(TargetToCompact(difficultyTarget(3)))
+		So(target.Cmp(difficultyTarget(3)), ShouldBeLessThanOrEqualTo, 0)
+		So(target.Cmp(difficultyTarget(4)), ShouldBeGreaterThan, 0)
+	})
+
+	Convey("a harder target is worth more work", t, func() {
+		So(Work(TargetToCompact(difficultyTarget(2))).Cmp(Work(TargetToCompact(difficultyTarget(1)))), ShouldBeGreaterThan, 0)
+		So(Work(TargetToCompact(difficultyTarget(1))).Int64(), ShouldEqual, 16)
+	})
+}
+
+func TestDifficulty_Retarget(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	g := testGenesis()
+	g.RetargetInterval = 4
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockTime := int64(g.BlockTime) * int64(time.Second)
+	bits := bc.chain[0].bits
+
+	// chainBefore builds the chain preceding a block at height, its blocks
+	// found spacing apart
+	chainBefore := func(height int, spacing int64) []*Block {
+		chain := []*Block{bc.chain[0]}
+		for i := 1; i < height; i++ {
+			chain = append(chain, NewBlock(0, chain[i-1].Hash(), BlockTimestamp+int64(i)*spacing, bits, nil))
+		}
+		return chain
+	}
+	targetOf := func(chain []*Block) *big.Int {
+		return CompactToTarget(bc.nextBits(chain))
+	}
+	start := CompactToTarget(bits)
+
+	Convey("the target only changes on retarget heights after the first window", t, func() {
+		So(bc.nextBits(chainBefore(3, blockTime/2)), ShouldEqual, bits)
+		So(bc.nextBits(chainBefore(4, blockTime/2)), ShouldEqual, bits)
+		So(bc.nextBits(chainBefore(7, blockTime/2)), ShouldEqual, bits)
+		So(bc.nextBits(chainBefore(8, blockTime/2)), ShouldNotEqual, bits)
+	})
+
+	Convey("the first retarget comes at twice the interval", t, func() {
+		for interval, want := range map[int]int{1: 3, 2: 4, 4: 8, DefaultRetargetInterval: 2 * DefaultRetargetInterval} {
+			g := testGenesis()
+			g.RetargetInterval = interval
+			first := 0
+			for height := 1; first == 0 && height <= 3*interval+1; height++ {
+				chain := chainBefore(height, blockTime/2)
+				if retarget(g, height, blocksAt(chain)) != bits {
+					first = height
+				}
+			}
+			So(first, ShouldEqual, want)
+		}
+	})
+
+	Convey("blocks found on time keep the target", t, func() {
+		So(bc.nextBits(chainBefore(8, blockTime)), ShouldEqual, bits)
+	})
+
+	Convey("blocks found twice as fast halve the target", t, func() {
+		want := new(big.Int).Div(start, big.NewInt(2))
+		So(CompactToTarget(TargetToCompact(want)).Cmp(targetOf(chainBefore(8, blockTime/2))), ShouldEqual, 0)
+	})
+
+	Convey("a retarget moves by at most MaxRetargetFactor", t, func() {
+		hardest := new(big.Int).Div(start, big.NewInt(MaxRetargetFactor))
+		So(targetOf(chainBefore(8, 1)).Cmp(CompactToTarget(TargetToCompact(hardest))), ShouldEqual, 0)
+		easiest := new(big.Int).Mul(start, big.NewInt(MaxRetargetFactor))
+		So(targetOf(chainBefore(8, 100*blockTime)).Cmp(CompactToTarget(TargetToCompact(easiest))), ShouldEqual, 0)
+	})
+
+	Convey("the target never gets easier than one leading zero digit", t, func() {
+		g := testGenesis()
+		g.Difficulty = 1
+		g.RetargetInterval = 4
+		easy, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		chain := []*Block{easy.chain[0]}
+		for i := 1; i < 8; i++ {
+			chain = append(chain, NewBlock(0, chain[i-1].Hash(), BlockTimestamp+int64(i)*100*blockTime, easy.chain[0].bits, nil))
+		}
+		So(CompactToTarget(easy.nextBits(chain)).Cmp(maxTarget), ShouldBeLessThanOrEqualTo, 0)
+	})
+}
+
+func TestDifficulty_MostWork(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	g := testGenesis()
+	g.Difficulty = 1
+	g.RetargetInterval = 2
+	blockTime := int64(g.BlockTime) * int64(time.Second)
+
+	// heavy finds blocks instantly, so its target keeps getting harder
+	heavy, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		mineBlock(heavy)
+	}
+	// light finds blocks slowly and stays at the easiest target
+	now := BlockTimestamp
+	slow := testGlobals(t, func() int64 {
+		now += MaxRetargetFactor * blockTime
+		return now
+	})
+	light, err := NewBlockchain(slow, NewMemoryStorage(), g, AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		mineBlock(light)
+	}
+
+	serve := func(bc *Blockchain) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			m, _ := json.Marshal(bc)
+			w.Write(m)
+		}))
+		t.Cleanup(server.Close)
+		return server.Listener.Addr().String()
+	}
+
+	Convey("the chain with the most work wins, not the longest one", t, func() {
+		So(len(light.chain), ShouldBeGreaterThan, len(heavy.chain))
+		So(chainWork(heavy.chain).Cmp(chainWork(light.chain)), ShouldBeGreaterThan, 0)
+
+		heavy.AddNeighbor(serve(light))
+		So(heavy.ResolveConflicts(), ShouldBeFalse)
+
+		light.AddNeighbor(serve(heavy))
+		So(light.ResolveConflicts(), ShouldBeTrue)
+		So(light.LastBlock().Hash(), ShouldEqual, heavy.LastBlock().Hash())
+		So(light.Height(), ShouldEqual, 7)
+	})
+
+	Convey("a mined chain declares the targets retargeting asks for", t, func() {
+		So(heavy.ValidChain(heavy.chain), ShouldBeNil)
+		So(heavy.LastBlock().bits, ShouldNotEqual, heavy.chain[0].bits)
+	})
+}
@@ -0,0 +1,246 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	blockLogName        = "blocks.log"
+	transactionPoolName = "pool.json"
+
+	// every record in the block log starts with the payload length and its
+	// CRC-32, followed by the block's JSON encoding
+	recordHeaderSize = 8
+	maxRecordSize    = 64 << 20
+)
+
+var errCorruptRecord = errors.New("corrupt block record")
+
+// FileStorage is a Storage kept in a directory. Blocks go to an append-only
+// log whose index of record offsets is rebuilt when the log is opened. The
+// transaction pool is a JSON snapshot replaced on every save.
+type FileStorage struct {
+	mux     sync.Mutex
+	dir     string
+	file    *os.File
+	offsets []int64
+	hashes  []types.Byte32
+	size    int64
+}
+
+// NewFileStorage opens or creates the storage in dir. A log ending in a
+// partial or damaged record, as left behind by a crash, is cut back to the
+// last intact block.
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, blockLogName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	fs := &FileStorage{dir: dir, file: f}
+	if err := fs.rebuildIndex(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return fs, nil
+}
+
+func (fs *FileStorage) rebuildIndex() error {
+	info, err := fs.file.Stat()
+	if err != nil {
+		return err
+	}
+	fs.offsets, fs.hashes, fs.size = nil, nil, 0
+
+	r := io.NewSectionReader(fs.file, 0, info.Size())
+	for {
+		b, n, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Printf("action=storage_recover offset=%d blocks=%d reason=%q", fs.size, len(fs.offsets), err)
+			if err := fs.file.Truncate(fs.size); err != nil {
+				return err
+			}
+			return fs.file.Sync()
+		}
+		fs.offsets = append(fs.offsets, fs.size)
+		fs.hashes = append(fs.hashes, b.Hash())
+		fs.size += n
+	}
+}
+
+// readRecord decodes the next block and returns the number of bytes it took.
+// io.EOF means the log ended cleanly on a record boundary.
+func readRecord(r io.Reader) (*Block, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, fmt.Errorf("%w: truncated header", errCorruptRecord)
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length > maxRecordSize {
+		return nil, 0, fmt.Errorf("%w: record of %d bytes", errCorruptRecord, length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, fmt.Errorf("%w: truncated payload", errCorruptRecord)
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, fmt.Errorf("%w: checksum mismatch", errCorruptRecord)
+	}
+	b := new(Block)
+	if err := json.Unmarshal(payload, b); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+	return b, int64(recordHeaderSize + length), nil
+}
+
+func (fs *FileStorage) Blocks() ([]*Block, error) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	blocks := make([]*Block, 0, len(fs.offsets))
+	r := io.NewSectionReader(fs.file, 0, fs.size)
+	for range fs.offsets {
+		b, _, err := readRecord(r)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+func (fs *FileStorage) AppendBlock(b *Block) error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	if err := fs.appendBlocks([]*Block{b}); err != nil {
+		return err
+	}
+	return fs.file.Sync()
+}
+
+func (fs *FileStorage) ReplaceBlocks(blocks []*Block) error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	common := 0
+	for common < len(blocks) && common < len(fs.hashes) && fs.hashes[common] == blocks[common].Hash() {
+		common++
+	}
+	if common < len(fs.offsets) {
+		if err := fs.file.Truncate(fs.offsets[common]); err != nil {
+			return err
+		}
+		fs.size = fs.offsets[common]
+		fs.offsets = fs.offsets[:common]
+		fs.hashes = fs.hashes[:common]
+	}
+	if err := fs.appendBlocks(blocks[common:]); err != nil {
+		return err
+	}
+	return fs.file.Sync()
+}
+
+// appendBlocks writes records at the end of the log. Callers must hold fs.mux.
+func (fs *FileStorage) appendBlocks(blocks []*Block) error {
+	for _, b := range blocks {
+		payload, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		record := make([]byte, recordHeaderSize+len(payload))
+		binary.BigEndian.PutUint32(record[:4], uint32(len(payload)))
+		binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+		copy(record[recordHeaderSize:], payload)
+		if _, err := fs.file.WriteAt(record, fs.size); err != nil {
+			return err
+		}
+		fs.offsets = append(fs.offsets, fs.size)
+		fs.hashes = append(fs.hashes, b.Hash())
+		fs.size += int64(len(record))
+	}
+	return nil
+}
+
+// Transactions reads the saved pool. Pools saved as a plain list of
+// transactions, before arrival times were kept, come back with Arrival 0.
+func (fs *FileStorage) Transactions() ([]*PendingTransaction, error) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	m, err := os.ReadFile(filepath.Join(fs.dir, transactionPoolName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pending []*PendingTransaction
+	if err := json.Unmarshal(m, &pending); err == nil && (len(pending) == 0 || pending[0].Transaction != nil) {
+		return pending, nil
+	}
+	var transactions []*Transaction
+	if err := json.Unmarshal(m, &transactions); err != nil {
+		return nil, err
+	}
+	pending = make([]*PendingTransaction, len(transactions))
+	for i, t := range transactions {
+		pending[i] = &PendingTransaction{Transaction: t}
+	}
+	return pending, nil
+}
+
+// SaveTransactions writes the pool to a temporary file first and renames it,
+// so a crash leaves either the old or the new snapshot behind.
+func (fs *FileStorage) SaveTransactions(transactions []*PendingTransaction) error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	if transactions == nil {
+		transactions = []*PendingTransaction{}
+	}
+	m, err := json.Marshal(transactions)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(fs.dir, transactionPoolName+".tmp")
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(m); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(fs.dir, transactionPoolName))
+}
+
+func (fs *FileStorage) Close() error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	return fs.file.Close()
+}
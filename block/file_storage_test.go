@@ -0,0 +1,175 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/globals"
+	"blockchain/wallet"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func openFileStorage(t *testing.T, dir string) *FileStorage {
+	fs, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fs.Close() })
+	return fs
+}
+
+// mineFileChain writes a genesis block plus two mined blocks to dir and
+// leaves one transaction pending.
+func mineFileChain(t *testing.T, gl globals.IGlobalLib, dir string) *Blockchain {
+	bc, err := NewBlockchain(gl, openFileStorage(t, dir), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	bc.SetBlockchainAddress(walletA.BlockchainAddress())
+	mineBlock(bc)
+	addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin/2)
+	mineBlock(bc)
+	addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin/4)
+	return bc
+}
+
+func TestFileStorage_Restart(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	dir := t.TempDir()
+	bc := mineFileChain(t, gl, dir)
+
+	Convey("a restarted node picks up where it stopped", t, func() {
+		restarted, err := NewBlockchain(gl, openFileStorage(t, dir), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		So(len(restarted.chain), ShouldEqual, 3)
+		for i := range bc.chain {
+			So(restarted.chain[i].Hash(), ShouldEqual, bc.chain[i].Hash())
+		}
+		So(restarted.transactionPool.Len(), ShouldEqual, 1)
+		So(restarted.TransactionPool()[0].ID(), ShouldEqual, bc.TransactionPool()[0].ID())
+	})
+}
+
+func TestFileStorage_Recover(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	logSize := func(dir string) int64 {
+		info, err := os.Stat(filepath.Join(dir, blockLogName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+
+	Convey("a partially written last block is dropped", t, func() {
+		dir := t.TempDir()
+		bc := mineFileChain(t, gl, dir)
+		So(os.Truncate(filepath.Join(dir, blockLogName), logSize(dir)-3), ShouldBeNil)
+
+		restarted, err := NewBlockchain(gl, openFileStorage(t, dir), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		So(len(restarted.chain), ShouldEqual, 2)
+		So(restarted.LastBlock().Hash(), ShouldEqual, bc.chain[1].Hash())
+
+		Convey("and the log accepts new blocks afterwards", func() {
+			mineBlock(restarted)
+			again, err := NewBlockchain(gl, openFileStorage(t, dir), testGenesis(), AccountMode)
+			So(err, ShouldBeNil)
+			So(len(again.chain), ShouldEqual, 3)
+			So(again.LastBlock().Hash(), ShouldEqual, restarted.LastBlock().Hash())
+		})
+	})
+
+	Convey("a last block with a damaged checksum is dropped", t, func() {
+		dir := t.TempDir()
+		mineFileChain(t, gl, dir)
+		f, err := os.OpenFile(filepath.Join(dir, blockLogName), os.O_RDWR, 0)
+		So(err, ShouldBeNil)
+		_, err = f.WriteAt([]byte{'#'}, logSize(dir)-2)
+		So(err, ShouldBeNil)
+		f.Close()
+
+		restarted, err := NewBlockchain(gl, openFileStorage(t, dir), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		So(len(restarted.chain), ShouldEqual, 2)
+	})
+
+	Convey("a stored block that is intact but invalid is dropped", t, func() {
+		dir := t.TempDir()
+		bc := mineFileChain(t, gl, dir)
+		forged := *bc.LastBlock()
+		forged.nonce++
+		fs := openFileStorage(t, dir)
+		So(fs.ReplaceBlocks(append(bc.chain[:2:2], &forged)), ShouldBeNil)
+		fs.Close()
+
+		restarted, err := NewBlockchain(gl, openFileStorage(t, dir), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		So(len(restarted.chain), ShouldEqual, 2)
+	})
+}
+
+func TestFileStorage_ReplaceBlocks(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	dir := t.TempDir()
+	bc := mineFileChain(t, gl, dir)
+	fs := bc.storage.(*FileStorage)
+
+	Convey("replacing keeps the common prefix and rewrites the rest", t, func() {
+		fork := NewBlock(42, bc.chain[1].Hash(), BlockTimestamp, bc.chain[1].bits, []*Transaction{})
+		size := fs.offsets[2]
+		So(fs.ReplaceBlocks([]*Block{bc.chain[0], bc.chain[1], fork}), ShouldBeNil)
+		So(fs.offsets[2], ShouldEqual, size)
+
+		blocks, err := fs.Blocks()
+		So(err, ShouldBeNil)
+		So(len(blocks), ShouldEqual, 3)
+		So(blocks[2].Hash(), ShouldEqual, fork.Hash())
+	})
+}
+
+func TestFileStorage_PoolArrival(t *testing.T) {
+	now := BlockTimestamp
+	clock := testGlobals(t, func() int64 { return now })
+
+	Convey("a restart keeps when pending transactions arrived", t, func() {
+		dir := t.TempDir()
+		mineFileChain(t, clock, dir)
+		now += int64(DefaultMempoolTTL - time.Hour)
+
+		restarted, err := NewBlockchain(clock, openFileStorage(t, dir), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		So(restarted.transactionPool.Pending(), ShouldHaveLength, 1)
+		So(restarted.transactionPool.Pending()[0].Arrival, ShouldEqual, BlockTimestamp)
+
+		Convey("and drops them once they waited past the TTL", func() {
+			now += int64(2 * time.Hour)
+			again, err := NewBlockchain(clock, openFileStorage(t, dir), testGenesis(), AccountMode)
+			So(err, ShouldBeNil)
+			So(again.TransactionPool(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("a pool saved without arrival times is loaded as just arrived", t, func() {
+		now = BlockTimestamp
+		dir := t.TempDir()
+		bc := mineFileChain(t, clock, dir)
+		m, err := json.Marshal(bc.TransactionPool())
+		So(err, ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, transactionPoolName), m, 0o600), ShouldBeNil)
+		now += int64(DefaultMempoolTTL + time.Hour)
+
+		restarted, err := NewBlockchain(clock, openFileStorage(t, dir), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		So(restarted.transactionPool.Pending(), ShouldHaveLength, 1)
+		So(restarted.transactionPool.Pending()[0].Arrival, ShouldEqual, now)
+	})
+}
@@ -0,0 +1,164 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	DefaultNetworkID        = "rythm-mainnet"
+	DefaultRetargetInterval = 10
+	// DefaultGenesisTimestamp is when the compiled-in genesis block was made.
+	DefaultGenesisTimestamp int64 = 1648402331651366000
+)
+
+// Allocation credits an address with coins in the genesis block.
+type Allocation struct {
+	Address string       `json:"address"`
+	Amount  types.Amount `json:"amount"`
+}
+
+// Genesis describes block 0 of a network. Nodes agree on a chain only if
+// they were started from the same specification.
+// Difficulty is the initial number of leading zero hex digits a block hash
+// needs. The target is retargeted every RetargetInterval blocks, from height
+// 2*RetargetInterval on, so that a block is found every BlockTime seconds.
+// The mining reward halves every HalvingInterval blocks and stops once
+// MaxSupply coins, allocations included, have been issued. Mined coins can be
+// spent once they are CoinbaseMaturity blocks deep. Each of the three is off
+// when zero.
+type Genesis struct {
+	NetworkID        string       `json:"network_id"`
+	Timestamp        int64        `json:"timestamp"`
+	Difficulty       int          `json:"difficulty"`
+	RetargetInterval int          `json:"retarget_interval"`
+	BlockTime        int          `json:"block_time"`
+	HalvingInterval  int          `json:"halving_interval,omitempty"`
+	MaxSupply        types.Amount `json:"max_supply,omitempty"`
+	CoinbaseMaturity int          `json:"coinbase_maturity,omitempty"`
+	Allocations      []Allocation `json:"allocations"`
+}
+
+// DefaultGenesis is the compiled-in specification used when no genesis file
+// is configured.
+func DefaultGenesis() *Genesis {
+	return &Genesis{
+		NetworkID:        DefaultNetworkID,
+		Timestamp:        DefaultGenesisTimestamp,
+		Difficulty:       MiningDifficulty,
+		RetargetInterval: DefaultRetargetInterval,
+		BlockTime:        MiningTimerSec,
+		HalvingInterval:  DefaultHalvingInterval,
+		MaxSupply:        DefaultMaxSupply,
+		CoinbaseMaturity: DevnetCoinbaseMaturity,
+		Allocations:      []Allocation{},
+	}
+}
+
+// LoadGenesis reads and validates a genesis file.
+func LoadGenesis(path string) (*Genesis, error) {
+	m, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	g := new(Genesis)
+	if err := json.Unmarshal(m, g); err != nil {
+		return nil, fmt.Errorf("reading genesis file %s: %w", path, err)
+	}
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf("genesis file %s: %w", path, err)
+	}
+	return g, nil
+}
+
+// Save writes the specification as indented JSON.
+func (g *Genesis) Save(path string) error {
+	m, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(m, '\n'), 0o644)
+}
+
+func (g *Genesis) Validate() error {
+	if g.NetworkID == "" {
+		return errors.New("network_id must not be empty")
+	}
+	if g.Difficulty < 1 || g.Difficulty > MaxDifficulty {
+		return fmt.Errorf("difficulty must be between 1 and %d", MaxDifficulty)
+	}
+	if g.RetargetInterval < 1 {
+		return errors.New("retarget_interval must be positive")
+	}
+	if g.BlockTime < 1 {
+		return errors.New("block_time must be positive")
+	}
+	if g.HalvingInterval < 0 {
+		return errors.New("halving_interval must not be negative")
+	}
+	if g.MaxSupply < 0 {
+		return errors.New("max_supply must not be negative")
+	}
+	if g.CoinbaseMaturity < 0 {
+		return errors.New("coinbase_maturity must not be negative")
+	}
+	var total types.Amount
+	// allocations are minted like the coinbase of block 0, so one per address
+	// keeps their ids apart
+	allocated := make(map[string]bool)
+	for _, a := range g.Allocations {
+		if a.Address == "" || a.Address == MiningSender {
+			return fmt.Errorf("invalid allocation address %q", a.Address)
+		}
+		if allocated[a.Address] {
+			return fmt.Errorf("address %s is allocated twice", a.Address)
+		}
+		allocated[a.Address] = true
+		if a.Amount <= 0 {
+			return fmt.Errorf("allocation to %s must be positive", a.Address)
+		}
+		var err error
+		if total, err = total.Add(a.Amount); err != nil {
+			return fmt.Errorf("allocations: %w", err)
+		}
+	}
+	if g.MaxSupply > 0 && total > g.MaxSupply {
+		return fmt.Errorf("allocations of %s exceed max_supply", total)
+	}
+	return nil
+}
+
+// Block builds block 0. A genesis block has no parent, so its previous hash
+// commits to the network parameters instead, which makes those part of the
+// genesis hash as well.
+func (g *Genesis) Block() *Block {
+	params, _ := json.Marshal(struct {
+		NetworkID        string       `json:"network_id"`
+		RetargetInterval int          `json:"retarget_interval"`
+		BlockTime        int          `json:"block_time"`
+		HalvingInterval  int          `json:"halving_interval,omitempty"`
+		MaxSupply        types.Amount `json:"max_supply,omitempty"`
+		CoinbaseMaturity int          `json:"coinbase_maturity,omitempty"`
+	}{
+		NetworkID:        g.NetworkID,
+		RetargetInterval: g.RetargetInterval,
+		BlockTime:        g.BlockTime,
+		HalvingInterval:  g.HalvingInterval,
+		MaxSupply:        g.MaxSupply,
+		CoinbaseMaturity: g.CoinbaseMaturity,
+	})
+	transactions := make([]*Transaction, 0, len(g.Allocations))
+	for _, a := range g.Allocations {
+		transactions = append(transactions, NewTransaction(MiningSender, a.Address, a.Amount))
+	}
+	bits := TargetToCompact(difficultyTarget(g.Difficulty))
+	return NewBlock(0, sha256.Sum256(params), g.Timestamp, bits, transactions)
+}
+
+func (g *Genesis) Hash() types.Byte32 {
+	return g.Block().Hash()
+}
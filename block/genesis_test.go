@@ -0,0 +1,166 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/wallet"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenesis_Block(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	Convey("the genesis block only depends on the specification", t, func() {
+		So(testGenesis().Hash(), ShouldEqual, testGenesis().Hash())
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		So(bc.chain[0].Hash(), ShouldEqual, testGenesis().Hash())
+		So(DefaultGenesis().Hash(), ShouldNotEqual, testGenesis().Hash())
+	})
+
+	Convey("every network parameter is part of the genesis hash", t, func() {
+		other := testGenesis()
+		other.NetworkID = "othernet"
+		So(other.Hash(), ShouldNotEqual, testGenesis().Hash())
+
+		harder := testGenesis()
+		harder.Difficulty++
+		So(harder.Hash(), ShouldNotEqual, testGenesis().Hash())
+
+		slower := testGenesis()
+		slower.BlockTime++
+		So(slower.Hash(), ShouldNotEqual, testGenesis().Hash())
+
+		capped := testGenesis()
+		capped.MaxSupply = 21 * types.Coin
+		So(capped.Hash(), ShouldNotEqual, testGenesis().Hash())
+
+		maturing := testGenesis()
+		maturing.CoinbaseMaturity = 100
+		So(maturing.Hash(), ShouldNotEqual, testGenesis().Hash())
+	})
+}
+
+func TestGenesis_Allocations(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	g := testGenesis()
+	g.Allocations = []Allocation{
+		{Address: walletA.BlockchainAddress(), Amount: 10 * types.Coin},
+		{Address: walletB.BlockchainAddress(), Amount: types.Coin / 2},
+	}
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("allocated coins can be spent right away", t, func() {
+		So(bc.CalculateTotalAmount(walletA.BlockchainAddress()), ShouldEqual, 10*types.Coin)
+		So(bc.CalculateTotalAmount(walletB.BlockchainAddress()), ShouldEqual, types.Coin/2)
+
+		So(addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), 3*types.Coin), ShouldBeNil)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.ValidChain(bc.chain), ShouldBeNil)
+		So(bc.CalculateTotalAmount(walletB.BlockchainAddress()), ShouldEqual, 3*types.Coin+types.Coin/2)
+	})
+}
+
+func TestGenesis_Mismatch(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	other := testGenesis()
+	other.NetworkID = "othernet"
+
+	Convey("a chain grown from another genesis block is rejected", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		foreign, err := NewBlockchain(gl, NewMemoryStorage(), other, AccountMode)
+		So(err, ShouldBeNil)
+		mineBlock(foreign)
+
+		var ce *ChainError
+		err = bc.ValidChain(foreign.chain)
+		So(errors.As(err, &ce), ShouldBeTrue)
+		So(ce.Height, ShouldEqual, 0)
+		So(errors.Is(err, ErrInvalidGenesis), ShouldBeTrue)
+	})
+
+	Convey("a node refuses to start on storage of another network", t, func() {
+		storage := NewMemoryStorage()
+		_, err := NewBlockchain(gl, storage, other, AccountMode)
+		So(err, ShouldBeNil)
+
+		_, err = NewBlockchain(nil, storage, testGenesis(), AccountMode)
+		So(errors.Is(err, ErrInvalidGenesis), ShouldBeTrue)
+	})
+
+	Convey("neighbors are checked against our genesis hash", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		served := testGenesis()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			m, _ := json.Marshal(&GenesisResponse{
+				NetworkID: served.NetworkID,
+				Hash:      fmt.Sprintf("%x", served.Hash()),
+			})
+			w.Write(m)
+		}))
+		defer server.Close()
+		neighbor := server.Listener.Addr().String()
+
+		So(bc.CheckGenesis(neighbor), ShouldBeNil)
+		served = other
+		So(errors.Is(bc.CheckGenesis(neighbor), ErrGenesisMismatch), ShouldBeTrue)
+	})
+}
+
+func TestGenesis_Load(t *testing.T) {
+	Convey("a saved genesis file loads back unchanged", t, func() {
+		g := testGenesis()
+		g.Allocations = []Allocation{{Address: wallet.NewWallet().BlockchainAddress(), Amount: 42 * types.Coin}}
+		path := filepath.Join(t.TempDir(), "genesis.json")
+		So(g.Save(path), ShouldBeNil)
+
+		loaded, err := LoadGenesis(path)
+		So(err, ShouldBeNil)
+		So(loaded, ShouldResemble, g)
+		So(loaded.Hash(), ShouldEqual, g.Hash())
+	})
+
+	Convey("invalid specifications are rejected", t, func() {
+		for _, mutate := range []func(g *Genesis){
+			func(g *Genesis) { g.NetworkID = "" },
+			func(g *Genesis) { g.Difficulty = 0 },
+			func(g *Genesis) { g.Difficulty = MaxDifficulty + 1 },
+			func(g *Genesis) { g.RetargetInterval = 0 },
+			func(g *Genesis) { g.BlockTime = -1 },
+			func(g *Genesis) { g.HalvingInterval = -1 },
+			func(g *Genesis) { g.MaxSupply = -1 },
+			func(g *Genesis) { g.CoinbaseMaturity = -1 },
+			func(g *Genesis) {
+				g.MaxSupply = types.Coin
+				g.Allocations = []Allocation{{Address: "a", Amount: 2 * types.Coin}}
+			},
+			func(g *Genesis) { g.Allocations = []Allocation{{Address: "", Amount: types.Coin}} },
+			func(g *Genesis) { g.Allocations = []Allocation{{Address: MiningSender, Amount: types.Coin}} },
+			func(g *Genesis) { g.Allocations = []Allocation{{Address: "a", Amount: 0}} },
+			func(g *Genesis) { g.Allocations = []Allocation{{Address: "a", Amount: 1}, {Address: "a", Amount: 2}} },
+			func(g *Genesis) {
+				g.Allocations = []Allocation{{Address: "a", Amount: types.Amount(1 << 62)}, {Address: "b", Amount: types.Amount(1 << 62)}}
+			},
+		} {
+			g := testGenesis()
+			mutate(g)
+			So(g.Validate(), ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// BlockHeader is the part of a block its hash and proof of work cover. The
+// transactions only enter it through their Merkle root, so a header is enough
+// to check the chain's linkage and work and, given a Merkle branch, that a
+// transaction was mined.
+type BlockHeader struct {
+	previousHash types.Byte32
+	merkleRoot   types.Byte32
+	timestamp    int64
+	bits         uint32
+	nonce        int
+}
+
+func (h *BlockHeader) PreviousHash() types.Byte32 {
+	return h.previousHash
+}
+
+func (h *BlockHeader) MerkleRoot() types.Byte32 {
+	return h.merkleRoot
+}
+
+func (h *BlockHeader) Timestamp() int64 {
+	return h.timestamp
+}
+
+func (h *BlockHeader) Bits() uint32 {
+	return h.bits
+}
+
+func (h *BlockHeader) Hash() types.Byte32 {
+	m, _ := json.Marshal(h)
+	return sha256.Sum256(m)
+}
+
+// ValidProof reports whether the header's hash, read as a big-endian number,
+// doesn't exceed the target it declares.
+func (h *BlockHeader) ValidProof() bool {
+	hash := h.Hash()
+	return new(big.Int).SetBytes(hash[:]).Cmp(CompactToTarget(h.bits)) <= 0
+}
+
+func (h *BlockHeader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		PreviousHash string `json:"previous_hash"`
+		MerkleRoot   string `json:"merkle_root"`
+		Timestamp    int64  `json:"timestamp"`
+		Bits         uint32 `json:"bits"`
+		Nonce        int    `json:"nonce"`
+	}{
+		PreviousHash: fmt.Sprintf("%x", h.previousHash),
+		MerkleRoot:   fmt.Sprintf("%x", h.merkleRoot),
+		Timestamp:    h.timestamp,
+		Bits:         h.bits,
+		Nonce:        h.nonce,
+	})
+}
+
+func (h *BlockHeader) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		PreviousHash *string `json:"previous_hash"`
+		MerkleRoot   *string `json:"merkle_root"`
+		Timestamp    *int64  `json:"timestamp"`
+		Bits         *uint32 `json:"bits"`
+		Nonce        *int    `json:"nonce"`
+	}{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	if v.PreviousHash == nil || v.MerkleRoot == nil || v.Timestamp == nil || v.Bits == nil || v.Nonce == nil {
+		return errors.New("block header is missing previous_hash, merkle_root, timestamp, bits or nonce")
+	}
+	previousHash, err := types.ParseByte32(*v.PreviousHash)
+	if err != nil {
+		return fmt.Errorf("malformed previous_hash: %w", err)
+	}
+	merkleRoot, err := types.ParseByte32(*v.MerkleRoot)
+	if err != nil {
+		return fmt.Errorf("malformed merkle_root: %w", err)
+	}
+	h.previousHash = previousHash
+	h.merkleRoot = merkleRoot
+	h.timestamp = *v.Timestamp
+	h.bits = *v.Bits
+	h.nonce = *v.Nonce
+	return nil
+}
+
+// Header returns the header of b.
+func (b *Block) Header() *BlockHeader {
+	return &BlockHeader{
+		previousHash: b.previousHash,
+		merkleRoot:   MerkleRoot(b.transactionIDs()),
+		timestamp:    b.timestamp,
+		bits:         b.bits,
+		nonce:        b.nonce,
+	}
+}
+
+func (b *Block) transactionIDs() []types.Byte32 {
+	ids := make([]types.Byte32, len(b.transactions))
+	for i, t := range b.transactions {
+		ids[i] = t.ID()
+	}
+	return ids
+}
@@ -0,0 +1,494 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/globals"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// HeadersPageSize is the most headers a node serves per request.
+	HeadersPageSize = 500
+	// LightClientReorgDepth is how many of its newest headers a light client
+	// downloads again on every sync, so that a reorganization that shallow
+	// doesn't cost it the whole chain.
+	LightClientReorgDepth = 20
+)
+
+var (
+	ErrUnknownHeader       = errors.New("proof refers to a block the light client has no header for")
+	ErrInvalidInclusion    = errors.New("transaction proof does not check out against the verified headers")
+	ErrBalanceMismatch     = errors.New("reported balance disagrees with the verified transactions")
+	ErrMissingTransactions = errors.New("node leaves out transactions other nodes proved")
+	ErrNoVerifiedAnswer    = errors.New("no node gave a verifiable answer")
+
+	errNotFound = errors.New("not found")
+)
+
+// HeadersResponse is a page of headers as served by GET /headers.
+type HeadersResponse struct {
+	Headers []*BlockHeader `json:"headers"`
+	Height  int            `json:"height"`
+}
+
+// LightClient follows a network by its block headers alone. It checks their
+// linkage, timestamps, targets and proof of work like a node would, keeps the
+// chain with the most work any node served, and checks what nodes say about
+// transactions and balances against it with Merkle proofs. Nodes are asked
+// independently, so one that lies or leaves things out shows up in the
+// reports next to the others.
+type LightClient struct {
+	globals globals.IGlobalLib
+	genesis *Genesis
+	nodes   []string
+	client  *http.Client
+
+	mux     sync.Mutex
+	headers []*BlockHeader
+	work    *big.Int
+}
+
+// NodeStatus is how a node's headers fared in the last sync.
+type NodeStatus struct {
+	Node   string `json:"node"`
+	Height int    `json:"height"`
+	Tip    string `json:"tip,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NodeBalance is what a node told about an address.
+type NodeBalance struct {
+	Node    string       `json:"node"`
+	Balance types.Amount `json:"balance"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// BalanceCheck is an address's balance as far as the light client could
+// verify it. Balance sums every transaction some node proved against the
+// headers up to Height; Consistent tells whether every node agreed with it.
+type BalanceCheck struct {
+	Address    string         `json:"address"`
+	Balance    types.Amount   `json:"balance"`
+	Height     int            `json:"height"`
+	Consistent bool           `json:"consistent"`
+	Nodes      []*NodeBalance `json:"nodes"`
+}
+
+// NodeInclusion is what a node told about a transaction.
+type NodeInclusion struct {
+	Node      string `json:"node"`
+	Confirmed bool   `json:"confirmed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// TransactionCheck tells whether a transaction is in the verified chain.
+type TransactionCheck struct {
+	ID          string           `json:"id"`
+	Confirmed   bool             `json:"confirmed"`
+	BlockHeight *int             `json:"block_height,omitempty"`
+	Consistent  bool             `json:"consistent"`
+	Nodes       []*NodeInclusion `json:"nodes"`
+}
+
+// NewLightClient returns a client of the network described by genesis that
+// asks the nodes at the given base URLs, e.g. http://127.0.0.1:5001. It knows
+// only the genesis header until the first Sync.
+func NewLightClient(globals globals.IGlobalLib, genesis *Genesis, nodes []string) (*LightClient, error) {
+	if err := genesis.Validate(); err != nil {
+		return nil, err
+	}
+	header := genesis.Block().Header()
+	return &LightClient{
+		globals: globals,
+		genesis: genesis,
+		nodes:   nodes,
+		client:  &http.Client{Timeout: time.Second * BlockchainRequestTimeoutSec},
+		headers: []*BlockHeader{header},
+		work:    Work(header.bits),
+	}, nil
+}
+
+func (lc *LightClient) Nodes() []string {
+	return lc.nodes
+}
+
+// Height is the height of the last verified header.
+func (lc *LightClient) Height() int {
+	lc.mux.Lock()
+	defer lc.mux.Unlock()
+	return len(lc.headers) - 1
+}
+
+func (lc *LightClient) Header(height int) (*BlockHeader, bool) {
+	lc.mux.Lock()
+	defer lc.mux.Unlock()
+
+	if height < 0 || height >= len(lc.headers) {
+		return nil, false
+	}
+	return lc.headers[height], true
+}
+
+// Sync downloads new headers from every node and switches to the valid chain
+// with the most work. Nodes whose headers don't validate are reported with
+// the reason.
+func (lc *LightClient) Sync() []*NodeStatus {
+	lc.mux.Lock()
+	local, localWork := lc.headers, lc.work
+	lc.mux.Unlock()
+
+	statuses := make([]*NodeStatus, 0, len(lc.nodes))
+	best, bestWork := local, localWork
+	for _, node := range lc.nodes {
+		status := &NodeStatus{Node: node}
+		statuses = append(statuses, status)
+
+		chain, from, err := lc.fetchHeaders(node, local)
+		if err == nil {
+			err = lc.validHeaders(chain, from)
+		}
+		if err != nil {
+			log.Printf("action=light_sync node=%s status=rejected err=%v", node, err)
+			status.Error = err.Error()
+			continue
+		}
+		status.Height = len(chain) - 1
+		status.Tip = fmt.Sprintf("%x", chain[len(chain)-1].Hash())
+
+		work := new(big.Int)
+		for _, h := range chain {
+			work.Add(work, Work(h.bits))
+		}
+		if work.Cmp(bestWork) > 0 {
+			best, bestWork = chain, work
+		}
+	}
+
+	lc.mux.Lock()
+	defer lc.mux.Unlock()
+	// another sync may have moved on in the meantime
+	if bestWork.Cmp(lc.work) > 0 {
+		lc.headers, lc.work = best, bestWork
+		log.Printf("action=light_sync status=updated height=%d work=%s", len(best)-1, bestWork)
+	}
+	return statuses
+}
+
+// fetchHeaders returns node's header chain. The first from headers are taken
+// from local and need no validation.
+func (lc *LightClient) fetchHeaders(node string, local []*BlockHeader) ([]*BlockHeader, int, error) {
+	from := len(local) - LightClientReorgDepth
+	if from < 1 {
+		from = 1
+	}
+	fetched, err := lc.headersFrom(node, from)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from > 1 && len(fetched) > 0 && fetched[0].previousHash != local[from-1].Hash() {
+		// the node forked off deeper than we look back, start over
+		from = 1
+		if fetched, err = lc.headersFrom(node, from); err != nil {
+			return nil, 0, err
+		}
+	}
+	chain := make([]*BlockHeader, 0, from+len(fetched))
+	chain = append(chain, local[:from]...)
+	return append(chain, fetched...), from, nil
+}
+
+// headersFrom pages through node's headers starting at height from.
+func (lc *LightClient) headersFrom(node string, from int) ([]*BlockHeader, error) {
+	var headers []*BlockHeader
+	for {
+		var page HeadersResponse
+		endpoint := fmt.Sprintf("%s/headers?from=%d&limit=%d", node, from+len(headers), HeadersPageSize)
+		if err := lc.get(endpoint, &page); err != nil {
+			return nil, err
+		}
+		for _, h := range page.Headers {
+			if h == nil {
+				return nil, errors.New("node served a null header")
+			}
+		}
+		headers = append(headers, page.Headers...)
+		if len(page.Headers) == 0 || from+len(headers) > page.Height {
+			return headers, nil
+		}
+	}
+}
+
+// validHeaders checks the headers of chain from height from on, the same way
+// ValidChain checks everything but the transactions of a block.
+func (lc *LightClient) validHeaders(chain []*BlockHeader, from int) error {
+	at := func(height int) (int64, uint32) {
+		return chain[height].timestamp, chain[height].bits
+	}
+	maxTime := lc.globals.NowUnixNano() + int64(MaxFutureBlockTime)
+	for height := from; height < len(chain); height++ {
+		h := chain[height]
+		var err error
+		switch {
+		case h.previousHash != chain[height-1].Hash():
+			err = ErrPreviousHash
+		case h.timestamp < medianTimeBelow(height, at) || h.timestamp > maxTime:
+			err = ErrInvalidTimestamp
+		case h.bits != retarget(lc.genesis, height, at):
+			err = ErrInvalidDifficulty
+		case !h.ValidProof():
+			err = ErrInvalidProof
+		}
+		if err != nil {
+			return &ChainError{Height: height, Hash: h.Hash(), Err: err}
+		}
+	}
+	return nil
+}
+
+// CheckTransaction asks every node for a proof that the transaction with
+// the given id was mined. It is confirmed if any node proves it against the
+// verified headers; nodes that deny it or serve a bad proof are reported.
+func (lc *LightClient) CheckTransaction(id types.Byte32) (*TransactionCheck, error) {
+	lc.Sync()
+
+	check := &TransactionCheck{ID: fmt.Sprintf("%x", id), Consistent: true}
+	answered := false
+	for _, node := range lc.nodes {
+		inclusion := &NodeInclusion{Node: node}
+		check.Nodes = append(check.Nodes, inclusion)
+
+		var proof TransactionProof
+		err := lc.get(fmt.Sprintf("%s/transactions/%x/proof", node, id), &proof)
+		switch {
+		case errors.Is(err, errNotFound):
+			answered = true
+		case err != nil:
+			inclusion.Error = err.Error()
+		default:
+			if err := lc.verifyProof(&proof, id, proof.BlockHeight); err != nil {
+				inclusion.Error = err.Error()
+				check.Consistent = false
+				continue
+			}
+			answered = true
+			inclusion.Confirmed = true
+			if !check.Confirmed {
+				height := proof.BlockHeight
+				check.Confirmed, check.BlockHeight = true, &height
+			}
+		}
+	}
+	if !answered {
+		return nil, ErrNoVerifiedAnswer
+	}
+	for _, inclusion := range check.Nodes {
+		if inclusion.Error == "" && inclusion.Confirmed != check.Confirmed {
+			check.Consistent = false
+		}
+	}
+	if !check.Consistent {
+		log.Printf("action=check_transaction id=%s status=inconsistent", check.ID)
+	}
+	return check, nil
+}
+
+// confirmedRef names one confirmed transaction by its id and the height of
+// the block holding it.
+type confirmedRef struct {
+	id     types.Byte32
+	height int
+}
+
+// CheckBalance works out the balance of address from the transactions the
+// nodes prove against the verified headers, and reports every node whose
+// history is incomplete, doesn't verify or doesn't add up to the balance it
+// claims.
+func (lc *LightClient) CheckBalance(address string) (*BalanceCheck, error) {
+	lc.Sync()
+
+	check := &BalanceCheck{Address: address, Height: lc.Height(), Consistent: true}
+	proved := make(map[confirmedRef]*Transaction)
+	seen := make([]map[confirmedRef]bool, len(lc.nodes))
+	for i, node := range lc.nodes {
+		balance := &NodeBalance{Node: node}
+		check.Nodes = append(check.Nodes, balance)
+
+		refs, err := lc.nodeBalance(node, address, balance, proved)
+		if err != nil {
+			balance.Error = err.Error()
+			continue
+		}
+		seen[i] = refs
+	}
+
+	answered := false
+	for _, refs := range seen {
+		answered = answered || refs != nil
+	}
+	if !answered {
+		return nil, ErrNoVerifiedAnswer
+	}
+
+	var err error
+	for _, t := range proved {
+		if check.Balance, err = applyToBalance(check.Balance, address, t); err != nil {
+			return nil, err
+		}
+	}
+	for i, balance := range check.Nodes {
+		switch {
+		case balance.Error != "":
+		case len(seen[i]) < len(proved):
+			balance.Error = ErrMissingTransactions.Error()
+		case balance.Balance != check.Balance:
+			balance.Error = ErrBalanceMismatch.Error()
+		}
+		if balance.Error != "" {
+			check.Consistent = false
+		}
+	}
+	if !check.Consistent {
+		log.Printf("action=check_balance address=%s status=inconsistent", address)
+	}
+	return check, nil
+}
+
+// nodeBalance fills in the balance node claims for address and proves every
+// confirmed transaction of its history, adding them to proved. It returns the
+// transactions the node listed.
+func (lc *LightClient) nodeBalance(node string, address string, balance *NodeBalance, proved map[confirmedRef]*Transaction) (map[confirmedRef]bool, error) {
+	var ar AmountResponse
+	if err := lc.get(fmt.Sprintf("%s/amount?blockchain_address=%s", node, url.QueryEscape(address)), &ar); err != nil {
+		return nil, err
+	}
+	balance.Balance = ar.Amount
+
+	statuses, err := lc.history(node, address)
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[confirmedRef]bool)
+	var listed types.Amount
+	for _, status := range statuses {
+		id, err := types.ParseByte32(status.ID)
+		if err != nil {
+			return nil, err
+		}
+		ref := confirmedRef{id: id, height: *status.BlockHeight}
+		if status.Transaction == nil || status.Transaction.ID() != id {
+			return nil, fmt.Errorf("%w: transaction %x doesn't match its id", ErrInvalidInclusion, id)
+		}
+		if _, ok := proved[ref]; !ok {
+			var proof TransactionProof
+			if err := lc.get(fmt.Sprintf("%s/transactions/%x/proof?height=%d", node, id, ref.height), &proof); err != nil {
+				return nil, fmt.Errorf("proof of %x: %w", id, err)
+			}
+			if err := lc.verifyProof(&proof, id, ref.height); err != nil {
+				return nil, err
+			}
+			proved[ref] = status.Transaction
+		}
+		refs[ref] = true
+		if listed, err = applyToBalance(listed, address, status.Transaction); err != nil {
+			return nil, err
+		}
+	}
+	if listed != balance.Balance {
+		return nil, fmt.Errorf("%w: claims %s, its history adds up to %s", ErrBalanceMismatch, balance.Balance, listed)
+	}
+	return refs, nil
+}
+
+// history returns every confirmed transaction node lists for address.
+func (lc *LightClient) history(node string, address string) ([]*TransactionStatus, error) {
+	var confirmed []*TransactionStatus
+	cursor := ""
+	for {
+		endpoint := fmt.Sprintf("%s/address/%s/transactions", node, url.PathEscape(address))
+		if cursor != "" {
+			endpoint += "?cursor=" + url.QueryEscape(cursor)
+		}
+		var page AddressHistory
+		if err := lc.get(endpoint, &page); err != nil {
+			return nil, err
+		}
+		for _, status := range page.Transactions {
+			if status == nil || status.Status != TransactionConfirmed {
+				continue
+			}
+			if status.BlockHeight == nil {
+				return nil, fmt.Errorf("%w: confirmed transaction without a height", ErrInvalidInclusion)
+			}
+			confirmed = append(confirmed, status)
+		}
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return confirmed, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// verifyProof checks that proof shows id in the block at height of the
+// verified chain.
+func (lc *LightClient) verifyProof(proof *TransactionProof, id types.Byte32, height int) error {
+	if proof.ID != fmt.Sprintf("%x", id) || proof.BlockHeight != height || !proof.Verify() {
+		return fmt.Errorf("%w: transaction %x", ErrInvalidInclusion, id)
+	}
+	header, ok := lc.Header(height)
+	if !ok {
+		return fmt.Errorf("%w: height %d", ErrUnknownHeader, height)
+	}
+	if header.Hash() != proof.Header.Hash() {
+		return fmt.Errorf("%w: transaction %x is in a block off the verified chain", ErrInvalidInclusion, id)
+	}
+	return nil
+}
+
+// applyToBalance returns the balance of address after t. The sender pays
+// the fee on top of the value.
+func applyToBalance(balance types.Amount, address string, t *Transaction) (types.Amount, error) {
+	var err error
+	for _, o := range t.Outputs() {
+		if o.Address != address {
+			continue
+		}
+		if balance, err = balance.Add(o.Value); err != nil {
+			return 0, err
+		}
+	}
+	if t.senderBlockchainAddress == address {
+		cost, err := t.cost()
+		if err != nil {
+			return 0, err
+		}
+		if balance, err = balance.Sub(cost); err != nil {
+			return 0, err
+		}
+	}
+	return balance, nil
+}
+
+func (lc *LightClient) get(endpoint string, v interface{}) error {
+	resp, err := lc.client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return json.NewDecoder(resp.Body).Decode(v)
+	case http.StatusNotFound:
+		return errNotFound
+	default:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
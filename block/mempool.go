@@ -0,0 +1,316 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultMempoolSize        = 5000
+	DefaultMempoolSenderLimit = 25
+	DefaultMempoolTTL         = 72 * time.Hour
+)
+
+var (
+	ErrMempoolFull    = errors.New("transaction pool is full of transactions paying at least the same fee rate")
+	ErrSenderPoolFull = errors.New("sender has too many pending transactions")
+	ErrNonceInUse     = errors.New("sender already has a pending transaction with this nonce")
+	ErrInputInUse     = errors.New("a pending transaction already spends one of the inputs")
+)
+
+// MempoolConfig bounds a Mempool. Zero fields fall back to the defaults.
+type MempoolConfig struct {
+	// MaxSize is the most transactions the pool holds.
+	MaxSize int
+	// MaxPerSender is the most pending transactions of a single sender.
+	MaxPerSender int
+	// TTL is how long a transaction may wait before it is dropped.
+	TTL time.Duration
+}
+
+func (c MempoolConfig) withDefaults() MempoolConfig {
+	if c.MaxSize <= 0 {
+		c.MaxSize = DefaultMempoolSize
+	}
+	if c.MaxPerSender <= 0 {
+		c.MaxPerSender = DefaultMempoolSenderLimit
+	}
+	if c.TTL <= 0 {
+		c.TTL = DefaultMempoolTTL
+	}
+	return c
+}
+
+// MempoolStats describes the pool and counts what left it other than by
+// being mined.
+type MempoolStats struct {
+	Size         int          `json:"size"`
+	MaxSize      int          `json:"max_size"`
+	MaxPerSender int          `json:"max_per_sender"`
+	TTLSeconds   int64        `json:"ttl_seconds"`
+	Fees         types.Amount `json:"fees"`
+	Evicted      int          `json:"evicted"`
+	Expired      int          `json:"expired"`
+	Invalidated  int          `json:"invalidated"`
+}
+
+// PendingTransaction is a transaction waiting in the pool together with
+// when the pool took it, in Unix nanoseconds, so that its TTL keeps running
+// across restarts.
+type PendingTransaction struct {
+	Transaction *Transaction `json:"transaction"`
+	Arrival     int64        `json:"arrival"`
+}
+
+type mempoolEntry struct {
+	transaction *Transaction
+	id          types.Byte32
+	// arrival is when the pool took the transaction, in Unix nanoseconds
+	arrival int64
+}
+
+// Mempool holds the pending transactions, highest fee rate first. When it is
+// full a transaction only gets in by paying a higher fee rate than the
+// lowest one waiting, which is evicted. A sender has at most one transaction
+// per nonce waiting; a transaction whose nonce follows a gap stays queued
+// until the gap is filled. No two transactions waiting spend the same
+// output. A Mempool doesn't check signatures, balances or
+// the chain's nonces; that is up to the Blockchain, whose mutex also guards
+// it.
+type Mempool struct {
+	config  MempoolConfig
+	entries []*mempoolEntry
+	ids     map[types.Byte32]bool
+	nonces  map[string]map[uint64]bool
+	spends  map[types.OutPoint]bool
+
+	evicted     int
+	expired     int
+	invalidated int
+}
+
+func NewMempool(config MempoolConfig) *Mempool {
+	return &Mempool{
+		config: config.withDefaults(),
+		ids:    make(map[types.Byte32]bool),
+		nonces: make(map[string]map[uint64]bool),
+		spends: make(map[types.OutPoint]bool),
+	}
+}
+
+func (mp *Mempool) Config() MempoolConfig {
+	return mp.config
+}
+
+// SetConfig changes the bounds, evicting the lowest fee rates if the pool
+// holds more than the new size.
+func (mp *Mempool) SetConfig(config MempoolConfig) {
+	mp.config = config.withDefaults()
+	for len(mp.entries) > mp.config.MaxSize {
+		mp.removeAt(len(mp.entries) - 1)
+		mp.evicted++
+	}
+}
+
+func (mp *Mempool) Len() int {
+	return len(mp.entries)
+}
+
+func (mp *Mempool) Has(id types.Byte32) bool {
+	return mp.ids[id]
+}
+
+// Spends reports whether a pending transaction spends the output op.
+func (mp *Mempool) Spends(op types.OutPoint) bool {
+	return mp.spends[op]
+}
+
+// NextNonce is the first nonce from on that sender has no transaction
+// waiting with.
+func (mp *Mempool) NextNonce(sender string, from uint64) uint64 {
+	for mp.nonces[sender][from] {
+		from++
+	}
+	return from
+}
+
+// Transactions returns the pending transactions, highest fee rate first.
+func (mp *Mempool) Transactions() []*Transaction {
+	transactions := make([]*Transaction, len(mp.entries))
+	for i, e := range mp.entries {
+		transactions[i] = e.transaction
+	}
+	return transactions
+}
+
+// Pending returns the pending transactions with their arrival times, highest
+// fee rate first.
+func (mp *Mempool) Pending() []*PendingTransaction {
+	pending := make([]*PendingTransaction, len(mp.entries))
+	for i, e := range mp.entries {
+		pending[i] = &PendingTransaction{Transaction: e.transaction, Arrival: e.arrival}
+	}
+	return pending
+}
+
+// Executable picks up to limit transactions that can go into a block in
+// the order given, highest fee rate first as far as nonces allow. next gives
+// the nonce the chain expects from a sender; a transaction is only picked
+// once those before it are.
+func (mp *Mempool) Executable(next func(sender string) uint64, limit int) []*Transaction {
+	picked := make([]*Transaction, 0)
+	taken := make([]bool, len(mp.entries))
+	expected := make(map[string]uint64)
+	// every pass picks what the previous one made executable
+	for progress := true; progress && len(picked) < limit; {
+		progress = false
+		for i, e := range mp.entries {
+			if taken[i] || len(picked) == limit {
+				continue
+			}
+			t := e.transaction
+			nonce, ok := expected[t.senderBlockchainAddress]
+			if !ok {
+				nonce = next(t.senderBlockchainAddress)
+			}
+			if t.nonce != nonce {
+				expected[t.senderBlockchainAddress] = nonce
+				continue
+			}
+			picked = append(picked, t)
+			taken[i] = true
+			expected[t.senderBlockchainAddress] = nonce + 1
+			progress = true
+		}
+	}
+	return picked
+}
+
+// Add puts t into the pool at time now. It fails with ErrNonceInUse when
+// the sender already has a transaction with the same nonce waiting, with
+// ErrInputInUse when a transaction waiting spends one of t's inputs, with
+// ErrSenderPoolFull when the sender has MaxPerSender transactions waiting and
+// with ErrMempoolFull when the pool is full and t doesn't outbid the lowest
+// fee rate in it.
+func (mp *Mempool) Add(t *Transaction, now int64) error {
+	pending := mp.nonces[t.senderBlockchainAddress]
+	if pending[t.nonce] {
+		return ErrNonceInUse
+	}
+	for _, in := range t.inputs {
+		if mp.spends[in] {
+			return ErrInputInUse
+		}
+	}
+	if len(pending) >= mp.config.MaxPerSender {
+		return ErrSenderPoolFull
+	}
+	if len(mp.entries) >= mp.config.MaxSize {
+		if !higherFeeRate(t, mp.entries[len(mp.entries)-1].transaction) {
+			return ErrMempoolFull
+		}
+		mp.removeAt(len(mp.entries) - 1)
+		mp.evicted++
+	}
+
+	// equal fee rates keep their arrival order
+	i := sort.Search(len(mp.entries), func(i int) bool {
+		return higherFeeRate(t, mp.entries[i].transaction)
+	})
+	mp.entries = append(mp.entries, nil)
+	copy(mp.entries[i+1:], mp.entries[i:])
+	mp.entries[i] = &mempoolEntry{transaction: t, id: t.ID(), arrival: now}
+	mp.ids[mp.entries[i].id] = true
+	if pending == nil {
+		pending = make(map[uint64]bool)
+		mp.nonces[t.senderBlockchainAddress] = pending
+	}
+	pending[t.nonce] = true
+	for _, in := range t.inputs {
+		mp.spends[in] = true
+	}
+	return nil
+}
+
+// Expired reports whether a transaction that arrived at arrival has waited
+// longer than the TTL at time now.
+func (mp *Mempool) Expired(arrival, now int64) bool {
+	return arrival < now-int64(mp.config.TTL)
+}
+
+// Expire drops the transactions that have waited longer than the TTL at time
+// now and returns how many there were.
+func (mp *Mempool) Expire(now int64) int {
+	n := mp.filter(func(e *mempoolEntry) bool { return !mp.Expired(e.arrival, now) })
+	mp.expired += n
+	return n
+}
+
+// RemoveMined drops the transactions mined reports as part of the chain.
+func (mp *Mempool) RemoveMined(mined func(id types.Byte32) bool) {
+	mp.filter(func(e *mempoolEntry) bool { return !mined(e.id) })
+}
+
+// RemoveInvalid drops the transactions valid rejects and returns how many
+// there were. valid sees them highest fee rate first.
+func (mp *Mempool) RemoveInvalid(valid func(t *Transaction) bool) int {
+	n := mp.filter(func(e *mempoolEntry) bool { return valid(e.transaction) })
+	mp.invalidated += n
+	return n
+}
+
+func (mp *Mempool) Stats() *MempoolStats {
+	stats := &MempoolStats{
+		Size:         len(mp.entries),
+		MaxSize:      mp.config.MaxSize,
+		MaxPerSender: mp.config.MaxPerSender,
+		TTLSeconds:   int64(mp.config.TTL / time.Second),
+		Evicted:      mp.evicted,
+		Expired:      mp.expired,
+		Invalidated:  mp.invalidated,
+	}
+	for _, e := range mp.entries {
+		// admission keeps every sender's costs within their balance
+		stats.Fees += e.transaction.fee
+	}
+	return stats
+}
+
+// filter keeps the entries keep accepts and returns how many it dropped.
+func (mp *Mempool) filter(keep func(e *mempoolEntry) bool) int {
+	kept := mp.entries[:0]
+	for _, e := range mp.entries {
+		if keep(e) {
+			kept = append(kept, e)
+			continue
+		}
+		mp.forget(e)
+	}
+	dropped := len(mp.entries) - len(kept)
+	for i := len(kept); i < len(mp.entries); i++ {
+		mp.entries[i] = nil
+	}
+	mp.entries = kept
+	return dropped
+}
+
+func (mp *Mempool) removeAt(i int) {
+	mp.forget(mp.entries[i])
+	copy(mp.entries[i:], mp.entries[i+1:])
+	mp.entries[len(mp.entries)-1] = nil
+	mp.entries = mp.entries[:len(mp.entries)-1]
+}
+
+func (mp *Mempool) forget(e *mempoolEntry) {
+	delete(mp.ids, e.id)
+	sender := e.transaction.senderBlockchainAddress
+	delete(mp.nonces[sender], e.transaction.nonce)
+	if len(mp.nonces[sender]) == 0 {
+		delete(mp.nonces, sender)
+	}
+	for _, in := range e.transaction.inputs {
+		delete(mp.spends, in)
+	}
+}
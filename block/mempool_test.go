@@ -0,0 +1,146 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/wallet"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// mempoolTestTransaction is the unsigned transfer with nonce n; a Mempool
+// doesn't check signatures.
+func mempoolTestTransaction(sender string, n int, fee types.Amount) *Transaction {
+	t := NewTransaction(sender, "recipient", types.Amount(n+1))
+	t.fee = fee
+	t.nonce = uint64(n)
+	return t
+}
+
+func TestMempool_Add(t *testing.T) {
+	Convey("a full pool evicts its lowest fee rate for a higher one", t, func() {
+		mp := NewMempool(MempoolConfig{MaxSize: 3})
+		for i, fee := range []types.Amount{20, 10, 30} {
+			So(mp.Add(mempoolTestTransaction(fmt.Sprint("s", i), i, fee), 0), ShouldBeNil)
+		}
+		So(errors.Is(mp.Add(mempoolTestTransaction("s3", 3, 10), 0), ErrMempoolFull), ShouldBeTrue)
+
+		So(mp.Add(mempoolTestTransaction("s4", 4, 15), 0), ShouldBeNil)
+		fees := []types.Amount{}
+		for _, t := range mp.Transactions() {
+			fees = append(fees, t.fee)
+		}
+		So(fees, ShouldResemble, []types.Amount{30, 20, 15})
+		So(mp.Stats().Evicted, ShouldEqual, 1)
+		So(mp.Stats().Fees, ShouldEqual, 65)
+	})
+
+	Convey("a sender can only have so many transactions waiting", t, func() {
+		mp := NewMempool(MempoolConfig{MaxPerSender: 2})
+		So(mp.Add(mempoolTestTransaction("a", 0, 1), 0), ShouldBeNil)
+		So(mp.Add(mempoolTestTransaction("a", 1, 1), 0), ShouldBeNil)
+		So(errors.Is(mp.Add(mempoolTestTransaction("a", 2, 100), 0), ErrSenderPoolFull), ShouldBeTrue)
+		So(mp.Add(mempoolTestTransaction("b", 0, 1), 0), ShouldBeNil)
+
+		mp.RemoveMined(func(id types.Byte32) bool { return id == mempoolTestTransaction("a", 0, 1).ID() })
+		So(mp.Add(mempoolTestTransaction("a", 2, 100), 0), ShouldBeNil)
+		So(mp.Len(), ShouldEqual, 3)
+	})
+
+	Convey("a sender has one transaction per nonce waiting", t, func() {
+		mp := NewMempool(MempoolConfig{})
+		So(mp.Add(mempoolTestTransaction("a", 0, 1), 0), ShouldBeNil)
+		replacement := mempoolTestTransaction("a", 0, 5)
+		replacement.recipientBlockchainAddress = "other"
+		So(errors.Is(mp.Add(replacement, 0), ErrNonceInUse), ShouldBeTrue)
+		So(mp.Add(mempoolTestTransaction("a", 2, 1), 0), ShouldBeNil)
+		So(mp.NextNonce("a", 0), ShouldEqual, 1)
+		So(mp.NextNonce("a", 2), ShouldEqual, 3)
+		So(mp.NextNonce("b", 7), ShouldEqual, 7)
+	})
+
+	Convey("transactions expire after the TTL", t, func() {
+		mp := NewMempool(MempoolConfig{TTL: time.Hour})
+		So(mp.Add(mempoolTestTransaction("a", 0, 1), 0), ShouldBeNil)
+		So(mp.Add(mempoolTestTransaction("b", 0, 1), int64(30*time.Minute)), ShouldBeNil)
+
+		So(mp.Expire(int64(time.Hour)), ShouldEqual, 0)
+		So(mp.Expire(int64(time.Hour)+1), ShouldEqual, 1)
+		So(mp.Has(mempoolTestTransaction("a", 0, 1).ID()), ShouldBeFalse)
+		So(mp.Has(mempoolTestTransaction("b", 0, 1).ID()), ShouldBeTrue)
+		So(mp.Stats().Expired, ShouldEqual, 1)
+	})
+}
+
+func TestMempool_Executable(t *testing.T) {
+	Convey("transactions are picked by fee rate as far as nonces allow", t, func() {
+		mp := NewMempool(MempoolConfig{})
+		So(mp.Add(mempoolTestTransaction("a", 1, 50), 0), ShouldBeNil)
+		So(mp.Add(mempoolTestTransaction("a", 0, 10), 0), ShouldBeNil)
+		So(mp.Add(mempoolTestTransaction("b", 5, 30), 0), ShouldBeNil)
+		So(mp.Add(mempoolTestTransaction("c", 0, 20), 0), ShouldBeNil)
+		// b's nonce 4 is missing
+		next := func(sender string) uint64 {
+			if sender == "b" {
+				return 4
+			}
+			return 0
+		}
+
+		picked := []string{}
+		for _, t := range mp.Executable(next, 10) {
+			picked = append(picked, fmt.Sprint(t.senderBlockchainAddress, t.nonce))
+		}
+		So(picked, ShouldResemble, []string{"c0", "a0", "a1"})
+		So(mp.Executable(next, 1), ShouldHaveLength, 1)
+	})
+}
+
+func TestBlockchain_Mempool(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+
+	Convey("transactions stuck past the TTL are dropped", t, func() {
+		now := BlockTimestamp
+		clock := testGlobals(t, func() int64 { return now })
+		g := testGenesis()
+		g.Allocations = []Allocation{{Address: walletA.BlockchainAddress(), Amount: types.Coin}}
+		bc, err := NewBlockchain(clock, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		bc.SetMempoolConfig(MempoolConfig{TTL: time.Hour})
+
+		So(addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin), ShouldBeNil)
+		now += int64(2 * time.Hour)
+		So(bc.MempoolStats().Expired, ShouldEqual, 1)
+		So(bc.TransactionPool(), ShouldBeEmpty)
+		// the coins are spendable again
+		So(addSignedTransactionWithFee(bc, walletA, walletB.BlockchainAddress(), types.Coin/2, 1), ShouldBeNil)
+	})
+
+	Convey("a reorganization drops transactions spending coins it undid", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		bc.SetBlockchainAddress(walletA.BlockchainAddress())
+		So(mineBlock(bc), ShouldBeTrue)
+		So(addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), MiningReward/2), ShouldBeNil)
+
+		other, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+		So(err, ShouldBeNil)
+		other.SetBlockchainAddress(walletB.BlockchainAddress())
+		So(mineBlock(other), ShouldBeTrue)
+		So(mineBlock(other), ShouldBeTrue)
+
+		bc.mux.Lock()
+		bc.setChain(other.chain)
+		bc.refreshTransactionPool()
+		bc.mux.Unlock()
+
+		So(bc.TransactionPool(), ShouldBeEmpty)
+		So(bc.MempoolStats().Invalidated, ShouldEqual, 1)
+	})
+}
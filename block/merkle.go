@@ -0,0 +1,127 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Leaves and inner nodes are hashed with different prefixes, so an inner
+// node can never be passed off as a transaction id. A node without a sibling
+// moves up a level unchanged instead of being paired with itself.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleStep is one level of a Merkle branch: the sibling hash and whether
+// it sits to the left of the path.
+type MerkleStep struct {
+	Hash types.Byte32
+	Left bool
+}
+
+func (s MerkleStep) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Hash string `json:"hash"`
+		Left bool   `json:"left"`
+	}{
+		Hash: fmt.Sprintf("%x", s.Hash),
+		Left: s.Left,
+	})
+}
+
+func (s *MerkleStep) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		Hash *string `json:"hash"`
+		Left bool    `json:"left"`
+	}{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	if v.Hash == nil {
+		return errors.New("merkle step is missing hash")
+	}
+	hash, err := types.ParseByte32(*v.Hash)
+	if err != nil {
+		return err
+	}
+	s.Hash, s.Left = hash, v.Left
+	return nil
+}
+
+func merkleLeaf(id types.Byte32) types.Byte32 {
+	return sha256.Sum256(append([]byte{merkleLeafPrefix}, id[:]...))
+}
+
+func merkleNode(left, right types.Byte32) types.Byte32 {
+	m := make([]byte, 0, 1+2*len(left))
+	m = append(m, merkleNodePrefix)
+	m = append(m, left[:]...)
+	m = append(m, right[:]...)
+	return sha256.Sum256(m)
+}
+
+// merkleLevels returns every level of the tree over ids, leaves first.
+func merkleLevels(ids []types.Byte32) [][]types.Byte32 {
+	level := make([]types.Byte32, len(ids))
+	for i, id := range ids {
+		level[i] = merkleLeaf(id)
+	}
+	levels := [][]types.Byte32{level}
+	for len(level) > 1 {
+		next := make([]types.Byte32, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				next = append(next, merkleNode(level[i], level[i+1]))
+			} else {
+				next = append(next, level[i])
+			}
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels
+}
+
+// MerkleRoot commits to ids in order. A block without transactions has the
+// zero root.
+func MerkleRoot(ids []types.Byte32) types.Byte32 {
+	if len(ids) == 0 {
+		return types.Byte32{}
+	}
+	levels := merkleLevels(ids)
+	return levels[len(levels)-1][0]
+}
+
+// MerkleBranch returns the siblings on the path from ids[index] to the root.
+func MerkleBranch(ids []types.Byte32, index int) []MerkleStep {
+	branch := []MerkleStep{}
+	levels := merkleLevels(ids)
+	for _, level := range levels[:len(levels)-1] {
+		switch {
+		case index%2 == 1:
+			branch = append(branch, MerkleStep{Hash: level[index-1], Left: true})
+		case index+1 < len(level):
+			branch = append(branch, MerkleStep{Hash: level[index+1]})
+		}
+		index /= 2
+	}
+	return branch
+}
+
+// VerifyMerkleBranch reports whether branch leads from the transaction id to
+// root.
+func VerifyMerkleBranch(id types.Byte32, branch []MerkleStep, root types.Byte32) bool {
+	hash := merkleLeaf(id)
+	for _, step := range branch {
+		if step.Left {
+			hash = merkleNode(step.Hash, hash)
+		} else {
+			hash = merkleNode(hash, step.Hash)
+		}
+	}
+	return hash == root
+}
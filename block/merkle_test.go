@@ -0,0 +1,68 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func merkleTestIDs(n int) []types.Byte32 {
+	ids := make([]types.Byte32, n)
+	for i := range ids {
+		ids[i] = types.Byte32{byte(i + 1)}
+	}
+	return ids
+}
+
+func TestMerkleRoot(t *testing.T) {
+	Convey("the root commits to every id and their order", t, func() {
+		So(MerkleRoot(nil), ShouldEqual, types.Byte32{})
+		So(MerkleRoot(merkleTestIDs(1)), ShouldEqual, merkleLeaf(merkleTestIDs(1)[0]))
+
+		ids := merkleTestIDs(3)
+		root := MerkleRoot(ids)
+		So(root, ShouldEqual, merkleNode(merkleNode(merkleLeaf(ids[0]), merkleLeaf(ids[1])), merkleLeaf(ids[2])))
+		So(MerkleRoot([]types.Byte32{ids[1], ids[0], ids[2]}), ShouldNotEqual, root)
+		// an odd id isn't paired with itself, so repeating it changes the root
+		So(MerkleRoot(append(ids, ids[2])), ShouldNotEqual, root)
+	})
+}
+
+func TestMerkleBranch(t *testing.T) {
+	Convey("every id's branch leads to the root", t, func() {
+		for n := 1; n <= 7; n++ {
+			ids := merkleTestIDs(n)
+			root := MerkleRoot(ids)
+			for i, id := range ids {
+				So(VerifyMerkleBranch(id, MerkleBranch(ids, i), root), ShouldBeTrue)
+			}
+		}
+	})
+
+	Convey("a branch doesn't verify anything else", t, func() {
+		ids := merkleTestIDs(5)
+		root := MerkleRoot(ids)
+		branch := MerkleBranch(ids, 2)
+
+		So(VerifyMerkleBranch(ids[3], branch, root), ShouldBeFalse)
+		So(VerifyMerkleBranch(ids[2], branch, types.Byte32{1}), ShouldBeFalse)
+		So(VerifyMerkleBranch(ids[2], branch[:len(branch)-1], root), ShouldBeFalse)
+
+		flipped := append([]MerkleStep{}, branch...)
+		flipped[0].Left = !flipped[0].Left
+		So(VerifyMerkleBranch(ids[2], flipped, root), ShouldBeFalse)
+	})
+
+	Convey("branches round-trip through JSON", t, func() {
+		ids := merkleTestIDs(6)
+		branch := MerkleBranch(ids, 5)
+		m, err := json.Marshal(branch)
+		So(err, ShouldBeNil)
+		var decoded []MerkleStep
+		So(json.Unmarshal(m, &decoded), ShouldBeNil)
+		So(decoded, ShouldResemble, branch)
+		So(json.Unmarshal([]byte(`[{"left":true}]`), &decoded), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,91 @@
+package block
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrStaleBlock = errors.New("another block extended the chain while mining")
+
+// Miner searches for a proof of work with several goroutines. Worker i of n
+// tries the nonces i, i+n, i+2n and so on, so no nonce is hashed twice.
+type Miner struct {
+	workers int
+
+	mux      sync.Mutex
+	hashes   uint64
+	duration time.Duration
+}
+
+// NewMiner creates a miner with the given number of workers, or one per CPU
+// when workers isn't positive.
+func NewMiner(workers int) *Miner {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &Miner{workers: workers}
+}
+
+func (m *Miner) Workers() int {
+	return m.workers
+}
+
+// HashRate is the number of hashes per second of the last search.
+func (m *Miner) HashRate() float64 {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.duration <= 0 {
+		return 0
+	}
+	return float64(m.hashes) / m.duration.Seconds()
+}
+
+// Solve returns a nonce for which template meets the target it declares.
+// template itself is left alone. Solve gives up with ctx.Err() once ctx is
+// done, and every worker has stopped by the time it returns.
+func (m *Miner) Solve(ctx context.Context, template *Block) (int, error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var hashes uint64
+	found := make(chan int, m.workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < m.workers; i++ {
+		wg.Add(1)
+		go func(nonce int) {
+			defer wg.Done()
+			// the header is all the hash covers, build it once
+			guess := template.Header()
+			for ; ; nonce += m.workers {
+				if ctx.Err() != nil {
+					return
+				}
+				guess.nonce = nonce
+				atomic.AddUint64(&hashes, 1)
+				if guess.ValidProof() {
+					found <- nonce
+					cancel()
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	m.mux.Lock()
+	m.hashes, m.duration = atomic.LoadUint64(&hashes), time.Since(start)
+	m.mux.Unlock()
+
+	select {
+	case nonce := <-found:
+		return nonce, nil
+	default:
+		return 0, ctx.Err()
+	}
+}
@@ -0,0 +1,104 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/wallet"
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// unsolvable is a difficulty no search finishes in a test's lifetime.
+const unsolvable = MaxDifficulty
+
+func TestMiner_Solve(t *testing.T) {
+	transactions := []*Transaction{NewTransaction(MiningSender, "miner", MiningReward)}
+	template := NewBlock(0, types.Byte32{7}, BlockTimestamp, TargetToCompact(difficultyTarget(MiningDifficulty)), transactions)
+
+	Convey("any number of workers finds a valid nonce", t, func() {
+		for _, workers := range []int{1, 3, 8} {
+			m := NewMiner(workers)
+			nonce, err := m.Solve(context.Background(), template)
+			So(err, ShouldBeNil)
+			So(template.nonce, ShouldEqual, 0)
+			solved := *template
+			solved.nonce = nonce
+			So(solved.ValidProof(), ShouldBeTrue)
+			So(m.HashRate(), ShouldBeGreaterThan, 0)
+		}
+	})
+
+	Convey("a miner without a worker count uses every CPU", t, func() {
+		So(NewMiner(0).Workers(), ShouldEqual, runtime.NumCPU())
+	})
+
+	Convey("cancelling the context stops the search", t, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		hard := NewBlock(0, types.Byte32{}, BlockTimestamp, TargetToCompact(difficultyTarget(unsolvable)), transactions)
+		_, err := NewMiner(2).Solve(ctx, hard)
+		So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+	})
+}
+
+func TestBlockchain_MineBlock(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	g := testGenesis()
+	g.Difficulty = unsolvable
+	g.Allocations = []Allocation{{Address: walletA.BlockchainAddress(), Amount: types.Coin}}
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mine := func(ctx context.Context) chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := bc.MineBlock(ctx)
+			done <- err
+		}()
+		// wait until the search has taken its snapshot
+		for {
+			bc.mux.Lock()
+			started := bc.cancelMining != nil
+			bc.mux.Unlock()
+			if started {
+				return done
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	Convey("the chain stays usable while a block is being mined", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := mine(ctx)
+
+		So(addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin/2), ShouldBeNil)
+		So(bc.CalculateTotalAmount(walletA.BlockchainAddress()), ShouldEqual, types.Coin)
+		So(bc.Height(), ShouldEqual, 0)
+
+		cancel()
+		So(errors.Is(<-done, context.Canceled), ShouldBeTrue)
+		So(bc.Height(), ShouldEqual, 0)
+		So(len(bc.TransactionPool()), ShouldEqual, 1)
+	})
+
+	Convey("a block from elsewhere interrupts the search", t, func() {
+		done := mine(context.Background())
+
+		bc.mux.Lock()
+		bc.extendChain(NewBlock(0, bc.LastBlock().Hash(), BlockTimestamp, bc.LastBlock().bits, []*Transaction{}))
+		bc.interruptMining()
+		bc.mux.Unlock()
+
+		So(errors.Is(<-done, ErrStaleBlock), ShouldBeTrue)
+		So(bc.Height(), ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,69 @@
+package block
+
+import "sync"
+
+// Storage keeps the chain and the transaction pool of a Blockchain across
+// restarts. Blocks are always handed over in height order.
+type Storage interface {
+	// Blocks returns every stored block, starting with the genesis block.
+	Blocks() ([]*Block, error)
+	// AppendBlock stores b on top of the stored chain.
+	AppendBlock(b *Block) error
+	// ReplaceBlocks swaps the stored chain for blocks, keeping whatever
+	// prefix the two have in common.
+	ReplaceBlocks(blocks []*Block) error
+	// Transactions returns the transaction pool saved last.
+	Transactions() ([]*PendingTransaction, error)
+	// SaveTransactions overwrites the saved transaction pool.
+	SaveTransactions(transactions []*PendingTransaction) error
+	Close() error
+}
+
+// MemoryStorage is a Storage that forgets everything on exit. It is meant for
+// tests and throwaway nodes.
+type MemoryStorage struct {
+	mux          sync.Mutex
+	blocks       []*Block
+	transactions []*PendingTransaction
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{}
+}
+
+func (ms *MemoryStorage) Blocks() ([]*Block, error) {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	return append([]*Block(nil), ms.blocks...), nil
+}
+
+func (ms *MemoryStorage) AppendBlock(b *Block) error {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	ms.blocks = append(ms.blocks, b)
+	return nil
+}
+
+func (ms *MemoryStorage) ReplaceBlocks(blocks []*Block) error {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	ms.blocks = append([]*Block(nil), blocks...)
+	return nil
+}
+
+func (ms *MemoryStorage) Transactions() ([]*PendingTransaction, error) {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	return append([]*PendingTransaction(nil), ms.transactions...), nil
+}
+
+func (ms *MemoryStorage) SaveTransactions(transactions []*PendingTransaction) error {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	ms.transactions = append([]*PendingTransaction(nil), transactions...)
+	return nil
+}
+
+func (ms *MemoryStorage) Close() error {
+	return nil
+}
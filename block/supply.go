@@ -0,0 +1,137 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"errors"
+)
+
+const (
+	DefaultHalvingInterval = 210_000
+	// DefaultMaxSupply is what the default halving schedule issues in
+	// total: MiningReward for every block of the first interval, half of it
+	// for the second and so on.
+	DefaultMaxSupply = 2 * DefaultHalvingInterval * MiningReward
+	// DefaultCoinbaseMaturity is what the genesis tool writes into new
+	// specifications. The compiled-in network, which starts without
+	// allocations, uses DevnetCoinbaseMaturity so that its miners can spend
+	// after a minute rather than after half an hour.
+	DefaultCoinbaseMaturity = 100
+	DevnetCoinbaseMaturity  = 3
+)
+
+var ErrImmatureCoinbase = errors.New("transaction spends mined coins before they have matured")
+
+// SupplyResponse answers GET /supply. Supply counts every coin issued up to
+// Height, allocations included; Circulating leaves out the mined coins that
+// can't be spent yet.
+type SupplyResponse struct {
+	Height           int          `json:"height"`
+	Supply           types.Amount `json:"supply"`
+	Circulating      types.Amount `json:"circulating"`
+	MaxSupply        types.Amount `json:"max_supply,omitempty"`
+	NextReward       types.Amount `json:"next_reward"`
+	HalvingInterval  int          `json:"halving_interval,omitempty"`
+	CoinbaseMaturity int          `json:"coinbase_maturity,omitempty"`
+}
+
+// Reward is what the coinbase of the block at height may issue on top of the
+// block's fees, given that issued coins exist before it. It starts out as
+// MiningReward, halves every HalvingInterval blocks and never takes the
+// supply past MaxSupply.
+func (g *Genesis) Reward(height int, issued types.Amount) types.Amount {
+	reward := MiningReward
+	if g.HalvingInterval > 0 {
+		if halvings := height / g.HalvingInterval; halvings < 63 {
+			reward >>= uint(halvings)
+		} else {
+			reward = 0
+		}
+	}
+	if g.MaxSupply > 0 && reward > g.MaxSupply-issued {
+		reward = g.MaxSupply - issued
+		if reward < 0 {
+			reward = 0
+		}
+	}
+	return reward
+}
+
+// immatureCoinbases returns the coinbase transactions of chain whose coins
+// the block after chain can't spend yet: those fewer than CoinbaseMaturity
+// blocks deep. Genesis allocations are spendable right away.
+func (g *Genesis) immatureCoinbases(chain []*Block) []*Transaction {
+	coinbases := []*Transaction{}
+	from := len(chain) - g.CoinbaseMaturity + 1
+	if from < 1 {
+		from = 1
+	}
+	for height := from; height < len(chain); height++ {
+		for _, t := range chain[height].transactions {
+			if t.senderBlockchainAddress == MiningSender {
+				coinbases = append(coinbases, t)
+			}
+		}
+	}
+	return coinbases
+}
+
+// immatureAmounts sums up the immature coins per address.
+func immatureAmounts(coinbases []*Transaction) map[string]types.Amount {
+	amounts := make(map[string]types.Amount)
+	for _, t := range coinbases {
+		for _, o := range t.Outputs() {
+			amounts[o.Address] += o.Value
+		}
+	}
+	return amounts
+}
+
+// immatureOutPoints names the outputs of the immature coinbases.
+func immatureOutPoints(coinbases []*Transaction) map[types.OutPoint]bool {
+	outPoints := make(map[types.OutPoint]bool)
+	for _, t := range coinbases {
+		id := t.ID()
+		for i := range t.Outputs() {
+			outPoints[types.OutPoint{TxID: id, Index: i}] = true
+		}
+	}
+	return outPoints
+}
+
+// issuance is how much b adds to the supply: what its coinbase pays out
+// beyond the fees it collects. For the genesis block that is the
+// allocations.
+func issuance(b *Block) types.Amount {
+	var issued types.Amount
+	for _, t := range b.transactions {
+		if t.senderBlockchainAddress == MiningSender {
+			issued += t.value
+		} else {
+			issued -= t.fee
+		}
+	}
+	return issued
+}
+
+// Supply reports how many coins the chain has issued and how many of them
+// can be spent.
+func (bc *Blockchain) Supply() *SupplyResponse {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	top := len(bc.chain) - 1
+	supply := bc.chainSupply[top]
+	circulating := supply
+	for _, amount := range immatureAmounts(bc.genesis.immatureCoinbases(bc.chain)) {
+		circulating -= amount
+	}
+	return &SupplyResponse{
+		Height:           top,
+		Supply:           supply,
+		Circulating:      circulating,
+		MaxSupply:        bc.genesis.MaxSupply,
+		NextReward:       bc.genesis.Reward(len(bc.chain), supply),
+		HalvingInterval:  bc.genesis.HalvingInterval,
+		CoinbaseMaturity: bc.genesis.CoinbaseMaturity,
+	}
+}
@@ -0,0 +1,195 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/wallet"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenesis_Reward(t *testing.T) {
+	Convey("the reward halves every interval", t, func() {
+		g := testGenesis()
+		g.HalvingInterval = 10
+		So(g.Reward(1, 0), ShouldEqual, MiningReward)
+		So(g.Reward(9, 0), ShouldEqual, MiningReward)
+		So(g.Reward(10, 0), ShouldEqual, MiningReward/2)
+		So(g.Reward(25, 0), ShouldEqual, MiningReward/4)
+		So(g.Reward(10*63, 0), ShouldEqual, 0)
+		So(g.Reward(10*1000, 0), ShouldEqual, 0)
+	})
+
+	Convey("the reward never takes the supply past the cap", t, func() {
+		g := testGenesis()
+		g.MaxSupply = 10 * MiningReward
+		So(g.Reward(1, 5*MiningReward), ShouldEqual, MiningReward)
+		So(g.Reward(1, 10*MiningReward-1), ShouldEqual, 1)
+		So(g.Reward(1, 10*MiningReward), ShouldEqual, 0)
+	})
+
+	Convey("without a schedule the reward stays the same", t, func() {
+		So(testGenesis().Reward(1_000_000, 1<<60), ShouldEqual, MiningReward)
+	})
+
+	Convey("the default schedule issues its max supply", t, func() {
+		g := DefaultGenesis()
+		So(g.Validate(), ShouldBeNil)
+		var issued types.Amount
+		for halving := 0; halving < 64; halving++ {
+			issued += types.Amount(g.HalvingInterval) * g.Reward(halving*g.HalvingInterval, 0)
+		}
+		So(issued, ShouldBeLessThanOrEqualTo, g.MaxSupply)
+		So(g.MaxSupply-issued, ShouldBeLessThan, 64*types.Amount(g.HalvingInterval))
+	})
+
+	Convey("miners on the compiled-in network can spend within minutes", t, func() {
+		g := DefaultGenesis()
+		So(g.Allocations, ShouldBeEmpty)
+		So(g.CoinbaseMaturity, ShouldBeGreaterThan, 0)
+		So(g.CoinbaseMaturity*g.BlockTime, ShouldBeLessThanOrEqualTo, 2*60)
+	})
+}
+
+func TestBlockchain_Supply(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	miner := wallet.NewWallet()
+
+	Convey("mined blocks follow the halving schedule", t, func() {
+		g := testGenesis()
+		g.HalvingInterval = 2
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		bc.SetBlockchainAddress(miner.BlockchainAddress())
+		for i := 0; i < 4; i++ {
+			So(mineBlock(bc), ShouldBeTrue)
+		}
+		So(bc.chain[1].transactions[0].value, ShouldEqual, MiningReward)
+		So(bc.chain[2].transactions[0].value, ShouldEqual, MiningReward/2)
+		So(bc.chain[3].transactions[0].value, ShouldEqual, MiningReward/2)
+		So(bc.chain[4].transactions[0].value, ShouldEqual, MiningReward/4)
+		So(bc.ValidChain(bc.chain), ShouldBeNil)
+
+		s := bc.Supply()
+		So(s.Height, ShouldEqual, 4)
+		So(s.Supply, ShouldEqual, 2*MiningReward+MiningReward/4)
+		So(s.Circulating, ShouldEqual, s.Supply)
+		So(s.NextReward, ShouldEqual, MiningReward/4)
+	})
+
+	Convey("mining stops paying out at the supply cap", t, func() {
+		g := testGenesis()
+		g.MaxSupply = 2*MiningReward + MiningReward/2
+		g.Allocations = []Allocation{{Address: wallet.NewWallet().BlockchainAddress(), Amount: MiningReward}}
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		bc.SetBlockchainAddress(miner.BlockchainAddress())
+		for i := 0; i < 3; i++ {
+			So(mineBlock(bc), ShouldBeTrue)
+		}
+		So(bc.chain[2].transactions[0].value, ShouldEqual, MiningReward/2)
+		So(bc.chain[3].transactions, ShouldBeEmpty)
+		So(bc.ValidChain(bc.chain), ShouldBeNil)
+		So(bc.Supply().Supply, ShouldEqual, g.MaxSupply)
+		So(bc.Supply().NextReward, ShouldEqual, 0)
+	})
+
+	Convey("a coinbase paying the reward from before a halving is invalid", t, func() {
+		g := testGenesis()
+		g.HalvingInterval = 1
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		So(mineBlock(bc), ShouldBeTrue)
+
+		chain := append([]*Block{}, bc.chain...)
+		tampered := *chain[1]
+		coinbase := *tampered.transactions[0]
+		coinbase.value = MiningReward
+		tampered.transactions = []*Transaction{&coinbase}
+		chain[1] = &tampered
+		So(errors.Is(bc.ValidChain(chain), ErrInvalidCoinbase), ShouldBeTrue)
+	})
+
+	Convey("fees move coins around without adding to the supply", t, func() {
+		sender := wallet.NewWallet()
+		g := testGenesis()
+		g.Allocations = []Allocation{{Address: sender.BlockchainAddress(), Amount: 10 * types.Coin}}
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		So(addSignedTransactionWithFee(bc, sender, miner.BlockchainAddress(), types.Coin, types.Coin/10), ShouldBeNil)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.Supply().Supply, ShouldEqual, 10*types.Coin+MiningReward)
+
+		bc.mux.Lock()
+		bc.truncateChain(1)
+		bc.mux.Unlock()
+		So(bc.Supply().Supply, ShouldEqual, 10*types.Coin)
+	})
+}
+
+func TestBlockchain_CoinbaseMaturity(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	miner := wallet.NewWallet()
+	recipient := wallet.NewWallet()
+	g := testGenesis()
+	g.CoinbaseMaturity = 3
+
+	Convey("mined coins can't be spent until they are deep enough", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		bc.SetBlockchainAddress(miner.BlockchainAddress())
+		So(mineBlock(bc), ShouldBeTrue)
+		err = addSignedTransaction(bc, miner, recipient.BlockchainAddress(), MiningReward/2)
+		So(errors.Is(err, ErrImmatureCoinbase), ShouldBeTrue)
+
+		So(mineBlock(bc), ShouldBeTrue)
+		So(mineBlock(bc), ShouldBeTrue)
+		s := bc.Supply()
+		So(s.Supply, ShouldEqual, 3*MiningReward)
+		So(s.Circulating, ShouldEqual, MiningReward)
+
+		So(addSignedTransaction(bc, miner, recipient.BlockchainAddress(), MiningReward/2), ShouldBeNil)
+		err = addSignedTransaction(bc, miner, recipient.BlockchainAddress(), MiningReward)
+		So(errors.Is(err, ErrImmatureCoinbase), ShouldBeTrue)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.ValidChain(bc.chain), ShouldBeNil)
+		So(bc.CalculateTotalAmount(recipient.BlockchainAddress()), ShouldEqual, MiningReward/2)
+	})
+
+	Convey("a block spending immature coins is invalid", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		bc.SetBlockchainAddress(miner.BlockchainAddress())
+		So(mineBlock(bc), ShouldBeTrue)
+
+		signed := wallet.NewTransaction(miner.PrivateKey(), miner.PublicKey(), miner.BlockchainAddress(), recipient.BlockchainAddress(), MiningReward, 0, 0)
+		spend := NewSignedTransaction(miner.BlockchainAddress(), recipient.BlockchainAddress(), MiningReward, 0, 0, miner.PublicKey(), signed.GenerateSignature())
+		chain := append([]*Block{}, bc.chain...)
+		chain = append(chain, NewBlock(0, chain[1].Hash(), BlockTimestamp, bc.nextBits(chain), []*Transaction{spend}))
+		So(errors.Is(bc.ValidChain(chain), ErrImmatureCoinbase), ShouldBeTrue)
+	})
+
+	Convey("immature outputs are held back in UTXO mode", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, UTXOMode)
+		So(err, ShouldBeNil)
+		bc.SetBlockchainAddress(miner.BlockchainAddress())
+		So(mineBlock(bc), ShouldBeTrue)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.UnspentOutputs(miner.BlockchainAddress()).Outputs, ShouldBeEmpty)
+
+		op := types.OutPoint{TxID: bc.chain[1].transactions[0].ID(), Index: 0}
+		outputs := []types.TxOutput{{Address: recipient.BlockchainAddress(), Value: MiningReward}}
+		_, err = addUTXOTransaction(bc, miner, []types.OutPoint{op}, outputs, 0, 0)
+		So(errors.Is(err, ErrImmatureCoinbase), ShouldBeTrue)
+
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.UnspentOutputs(miner.BlockchainAddress()).Outputs, ShouldHaveLength, 1)
+		_, err = addPayment(bc, miner, recipient.BlockchainAddress(), MiningReward/2, 0)
+		So(err, ShouldBeNil)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.ValidChain(bc.chain), ShouldBeNil)
+	})
+}
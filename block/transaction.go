@@ -1,18 +1,34 @@
 package block
 
 import (
+	types "blockchain/blockchaintypes"
+	"blockchain/globals"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 	"strings"
 )
 
+var gl = globals.NewGlobals()
+
 type Transaction struct {
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
-	value                      float32
+	value                      types.Amount
+	fee                        types.Amount
+	nonce                      uint64
+	inputs                     []types.OutPoint
+	outputs                    []types.TxOutput
+	senderPublicKey            *ecdsa.PublicKey
+	signature                  *globals.Signature
 }
 
-func NewTransaction(sender string, recipient string, value float32) *Transaction {
+func NewTransaction(sender string, recipient string, value types.Amount) *Transaction {
 	return &Transaction{
 		senderBlockchainAddress:    sender,
 		recipientBlockchainAddress: recipient,
@@ -20,21 +36,305 @@ func NewTransaction(sender string, recipient string, value float32) *Transaction
 	}
 }
 
+// NewSignedTransaction keeps the sender's public key and signature with the
+// transaction so the block it ends up in can be verified later on.
+func NewSignedTransaction(
+	sender string,
+	recipient string,
+	value types.Amount,
+	fee types.Amount,
+	nonce uint64,
+	senderPublicKey *ecdsa.PublicKey,
+	s *globals.Signature) *Transaction {
+	t := NewTransaction(sender, recipient, value)
+	t.fee = fee
+	t.nonce = nonce
+	t.senderPublicKey = senderPublicKey
+	t.signature = s
+	return t
+}
+
+// NewSignedUTXOTransaction spends the sender's outputs named by inputs and
+// creates outputs in their place. The recipient is the address of the first
+// output and the value the sum of all of them, so the transaction reads like
+// a transfer wherever only those matter.
+func NewSignedUTXOTransaction(
+	sender string,
+	inputs []types.OutPoint,
+	outputs []types.TxOutput,
+	fee types.Amount,
+	nonce uint64,
+	senderPublicKey *ecdsa.PublicKey,
+	s *globals.Signature) *Transaction {
+	var recipient string
+	if len(outputs) > 0 {
+		recipient = outputs[0].Address
+	}
+	// an overflowing sum is caught by validOutputs
+	value, _ := sumOutputs(outputs)
+	t := NewSignedTransaction(sender, recipient, value, fee, nonce, senderPublicKey, s)
+	t.inputs = inputs
+	t.outputs = outputs
+	return t
+}
+
+func sumOutputs(outputs []types.TxOutput) (types.Amount, error) {
+	var sum types.Amount
+	for _, o := range outputs {
+		var err error
+		if sum, err = sum.Add(o.Value); err != nil {
+			return 0, err
+		}
+	}
+	return sum, nil
+}
+
 func (t *Transaction) Print() {
 	fmt.Printf("%v\n", strings.Repeat("~", 42))
 	fmt.Printf("\tsendBlockchainAddress        %s\n", t.senderBlockchainAddress)
 	fmt.Printf("\trecipientBlockchainAddress   %s\n", t.recipientBlockchainAddress)
-	fmt.Printf("\tvalue                        %.2f\n", t.value)
+	fmt.Printf("\tvalue                        %s\n", t.value)
+	fmt.Printf("\tfee                          %s\n", t.fee)
+	fmt.Printf("\tnonce                        %d\n", t.nonce)
+	for _, in := range t.inputs {
+		fmt.Printf("\tinput                        %s\n", in)
+	}
+	for _, o := range t.outputs {
+		fmt.Printf("\toutput                       %s %s\n", o.Address, o.Value)
+	}
 }
 
-func (t *Transaction) MarshalJSON() ([]byte, error) {
+// NewCoinbaseTransaction pays value to the miner of the block at height.
+// The height serves as nonce, so every coinbase has an id of its own.
+func NewCoinbaseTransaction(recipient string, value types.Amount, height int) *Transaction {
+	t := NewTransaction(MiningSender, recipient, value)
+	t.nonce = uint64(height)
+	return t
+}
+
+func (t *Transaction) Fee() types.Amount {
+	return t.fee
+}
+
+// Nonce numbers the sender's transactions from zero. A chain takes them in
+// order, each exactly once, so a signed transfer can't be replayed.
+func (t *Transaction) Nonce() uint64 {
+	return t.nonce
+}
+
+// Inputs are the outputs a UTXO transaction spends.
+func (t *Transaction) Inputs() []types.OutPoint {
+	return t.inputs
+}
+
+// Outputs are the coins the transaction creates. A plain transfer and a
+// coinbase create a single output paying value to the recipient.
+func (t *Transaction) Outputs() []types.TxOutput {
+	if t.spendsOutputs() {
+		return t.outputs
+	}
+	return []types.TxOutput{{Address: t.recipientBlockchainAddress, Value: t.value}}
+}
+
+// spendsOutputs reports whether t is a UTXO transaction rather than a
+// transfer between accounts.
+func (t *Transaction) spendsOutputs() bool {
+	return len(t.outputs) > 0
+}
+
+// validOutputs reports whether every output pays a positive value to an
+// address other than the mining sender and whether they add up to the value.
+func (t *Transaction) validOutputs() bool {
+	for _, o := range t.Outputs() {
+		if o.Value <= 0 || o.Address == "" || o.Address == MiningSender {
+			return false
+		}
+	}
+	sum, err := sumOutputs(t.Outputs())
+	return err == nil && sum == t.value
+}
+
+// addresses lists the sender followed by every other address t pays.
+func (t *Transaction) addresses() []string {
+	addresses := []string{t.senderBlockchainAddress}
+	for _, o := range t.Outputs() {
+		known := false
+		for _, a := range addresses {
+			known = known || a == o.Address
+		}
+		if !known {
+			addresses = append(addresses, o.Address)
+		}
+	}
+	return addresses
+}
+
+// involves reports whether address sends or receives coins with t.
+func (t *Transaction) involves(address string) bool {
+	for _, a := range t.addresses() {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// cost is what the transaction takes from the sender: its value plus fee.
+func (t *Transaction) cost() (types.Amount, error) {
+	return t.value.Add(t.fee)
+}
+
+// size is the length of the transaction's encoding, which its fee rate is
+// measured against.
+func (t *Transaction) size() int {
+	m, _ := t.MarshalJSON()
+	return len(m)
+}
+
+// higherFeeRate reports whether a pays more fee per byte than b.
+func higherFeeRate(a, b *Transaction) bool {
+	left := new(big.Int).Mul(big.NewInt(int64(a.fee)), big.NewInt(int64(b.size())))
+	right := new(big.Int).Mul(big.NewInt(int64(b.fee)), big.NewInt(int64(a.size())))
+	return left.Cmp(right) > 0
+}
+
+// ID identifies a transaction across nodes. It is the hash of the signed
+// payload only: a signature can be altered without invalidating it, and such
+// a copy must not pass for a new transaction.
+func (t *Transaction) ID() types.Byte32 {
+	m, _ := t.SignedPayload()
+	return sha256.Sum256(m)
+}
+
+// SignedPayload returns the bytes covered by the sender's signature. It must
+// stay in line with wallet.Transaction.MarshalJSON.
+func (t *Transaction) SignedPayload() ([]byte, error) {
 	return json.Marshal(struct {
-		Sender    string  `json:"sender_blockchain_address"`
-		Recipient string  `json:"recipient_blockchain_address"`
-		Value     float32 `json:"value"`
+		Sender    string           `json:"sender_blockchain_address"`
+		Recipient string           `json:"recipient_blockchain_address"`
+		Value     types.Amount     `json:"value"`
+		Fee       types.Amount     `json:"fee"`
+		Nonce     uint64           `json:"nonce"`
+		Inputs    []types.OutPoint `json:"inputs,omitempty"`
+		Outputs   []types.TxOutput `json:"outputs,omitempty"`
 	}{
 		Sender:    t.senderBlockchainAddress,
 		Recipient: t.recipientBlockchainAddress,
 		Value:     t.value,
+		Fee:       t.fee,
+		Nonce:     t.nonce,
+		Inputs:    t.inputs,
+		Outputs:   t.outputs,
+	})
+}
+
+func (t *Transaction) MarshalJSON() ([]byte, error) {
+	var publicKey, signature string
+	if t.senderPublicKey != nil {
+		publicKey = fmt.Sprintf("%064x%064x", t.senderPublicKey.X.Bytes(), t.senderPublicKey.Y.Bytes())
+	}
+	if t.signature != nil {
+		signature = t.signature.String()
+	}
+	return json.Marshal(struct {
+		Sender          string           `json:"sender_blockchain_address"`
+		Recipient       string           `json:"recipient_blockchain_address"`
+		Value           types.Amount     `json:"value"`
+		Fee             types.Amount     `json:"fee"`
+		Nonce           uint64           `json:"nonce"`
+		Inputs          []types.OutPoint `json:"inputs,omitempty"`
+		Outputs         []types.TxOutput `json:"outputs,omitempty"`
+		SenderPublicKey string           `json:"sender_public_key,omitempty"`
+		Signature       string           `json:"signature,omitempty"`
+	}{
+		Sender:          t.senderBlockchainAddress,
+		Recipient:       t.recipientBlockchainAddress,
+		Value:           t.value,
+		Fee:             t.fee,
+		Nonce:           t.nonce,
+		Inputs:          t.inputs,
+		Outputs:         t.outputs,
+		SenderPublicKey: publicKey,
+		Signature:       signature,
 	})
 }
+
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		Sender          *string          `json:"sender_blockchain_address"`
+		Recipient       *string          `json:"recipient_blockchain_address"`
+		Value           *types.Amount    `json:"value"`
+		Fee             types.Amount     `json:"fee"`
+		Nonce           uint64           `json:"nonce"`
+		Inputs          []types.OutPoint `json:"inputs"`
+		Outputs         []types.TxOutput `json:"outputs"`
+		SenderPublicKey string           `json:"sender_public_key"`
+		Signature       string           `json:"signature"`
+	}{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	if len(v.Outputs) > 0 {
+		if v.Sender == nil {
+			return errors.New("transaction is missing sender")
+		}
+		u := NewSignedUTXOTransaction(*v.Sender, v.Inputs, v.Outputs, v.Fee, v.Nonce, nil, nil)
+		if (v.Recipient != nil && *v.Recipient != u.recipientBlockchainAddress) || (v.Value != nil && *v.Value != u.value) {
+			return errors.New("transaction recipient and value don't match its outputs")
+		}
+		v.Recipient, v.Value = &u.recipientBlockchainAddress, &u.value
+	} else if len(v.Inputs) > 0 {
+		return errors.New("transaction spends inputs without creating outputs")
+	}
+	if v.Sender == nil || v.Recipient == nil || v.Value == nil {
+		return errors.New("transaction is missing sender, recipient or value")
+	}
+	t.senderBlockchainAddress = *v.Sender
+	t.recipientBlockchainAddress = *v.Recipient
+	t.value = *v.Value
+	t.fee = v.Fee
+	t.nonce = v.Nonce
+	t.inputs = nil
+	t.outputs = nil
+	if len(v.Outputs) > 0 {
+		t.inputs = v.Inputs
+		t.outputs = v.Outputs
+	}
+	t.senderPublicKey = nil
+	t.signature = nil
+	if v.SenderPublicKey != "" {
+		if !isPublicKey(v.SenderPublicKey) {
+			return fmt.Errorf("malformed sender_public_key %q", v.SenderPublicKey)
+		}
+		t.senderPublicKey = gl.PublicKeyFromString(v.SenderPublicKey)
+	}
+	if v.Signature != "" {
+		if !isHexPair(v.Signature) {
+			return fmt.Errorf("malformed signature %q", v.Signature)
+		}
+		t.signature = gl.SignatureFromString(v.Signature)
+	}
+	return nil
+}
+
+// isHexPair reports whether s holds two 32 byte big-endian integers in hex,
+// the encoding used for public keys and signatures.
+func isHexPair(s string) bool {
+	if len(s) != 128 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// isPublicKey reports whether s is a hex pair naming a point on P-256, the
+// curve every key in the chain uses. Signatures checked against any other
+// point mean nothing.
+func isPublicKey(s string) bool {
+	if !isHexPair(s) {
+		return false
+	}
+	x, _ := new(big.Int).SetString(s[:64], 16)
+	y, _ := new(big.Int).SetString(s[64:], 16)
+	return elliptic.P256().IsOnCurve(x, y)
+}
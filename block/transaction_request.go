@@ -1,24 +1,72 @@
 package block
 
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/wallet"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidRequest = errors.New("invalid payload")
+
+// TransactionRequest is a signed transaction as clients and neighbors submit
+// it. A UTXO transaction comes with inputs and outputs, which its recipient
+// and value follow from, so those can be left out.
 type TransactionRequest struct {
-	SenderBlockchainAddress    *string  `json:"sender_blockchain_address"`
-	RecipientBlockchainAddress *string  `json:"recipient_blockchain_address"`
-	SenderPublicKey            *string  `json:"sender_public_key"`
-	Value                      *float32 `json:"value"`
-	Signature                  *string  `json:"signature"`
+	SenderBlockchainAddress    *string          `json:"sender_blockchain_address"`
+	RecipientBlockchainAddress *string          `json:"recipient_blockchain_address"`
+	SenderPublicKey            *string          `json:"sender_public_key"`
+	Value                      *types.Amount    `json:"value"`
+	Fee                        *types.Amount    `json:"fee,omitempty"`
+	Nonce                      *uint64          `json:"nonce,omitempty"`
+	Signature                  *string          `json:"signature"`
+	Inputs                     []types.OutPoint `json:"inputs,omitempty"`
+	Outputs                    []types.TxOutput `json:"outputs,omitempty"`
 }
 
-func (tr *TransactionRequest) Validate() bool {
+// Validate checks that the fields a transaction needs are there and that the
+// public key and signature are encoded the way they are decoded later, the
+// public key as a point on P-256.
+func (tr *TransactionRequest) Validate() error {
 	if tr.SenderBlockchainAddress == nil ||
-		tr.RecipientBlockchainAddress == nil ||
 		tr.SenderPublicKey == nil ||
-		tr.Value == nil ||
 		tr.Signature == nil ||
 		*tr.SenderBlockchainAddress == "" ||
-		*tr.RecipientBlockchainAddress == "" ||
 		*tr.SenderPublicKey == "" ||
 		*tr.Signature == "" {
-		return false
+		return fmt.Errorf("%w: sender_blockchain_address, sender_public_key and signature are required", ErrInvalidRequest)
+	}
+	if !isPublicKey(*tr.SenderPublicKey) {
+		return fmt.Errorf("%w: malformed sender_public_key", ErrInvalidRequest)
+	}
+	if !isHexPair(*tr.Signature) {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidRequest)
+	}
+	if len(tr.Outputs) > 0 {
+		return nil
+	}
+	if tr.RecipientBlockchainAddress == nil ||
+		tr.Value == nil ||
+		*tr.RecipientBlockchainAddress == "" {
+		return fmt.Errorf("%w: recipient_blockchain_address and value are required", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// ValidateAddresses checks that the recipient, or in a UTXO transaction the
+// address of every output, is well formed, so that coins are not sent to an
+// address nobody can hold the key of. It expects a request Validate passed.
+func (tr *TransactionRequest) ValidateAddresses() error {
+	if len(tr.Outputs) > 0 {
+		for _, o := range tr.Outputs {
+			if !wallet.IsValidAddress(o.Address) {
+				return fmt.Errorf("%w: %q", wallet.ErrInvalidAddress, o.Address)
+			}
+		}
+		return nil
+	}
+	if !wallet.IsValidAddress(*tr.RecipientBlockchainAddress) {
+		return fmt.Errorf("%w: %q", wallet.ErrInvalidAddress, *tr.RecipientBlockchainAddress)
 	}
-	return true
+	return nil
 }
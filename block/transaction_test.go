@@ -0,0 +1,97 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/globals"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/json"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// randomTransaction builds an arbitrary transaction, a transfer or one
+// spending outputs, signed or not, for property tests. Signatures don't have
+// to verify to round-trip, but keys have to be points on the curve to be
+// decoded.
+func randomTransaction(r *rand.Rand) *Transaction {
+	randString := func() string {
+		v, _ := quick.Value(reflect.TypeOf(""), r)
+		return v.String()
+	}
+	randInt256 := func() *big.Int {
+		b := make([]byte, r.Intn(33))
+		r.Read(b)
+		return new(big.Int).SetBytes(b)
+	}
+
+	var t *Transaction
+	if r.Intn(2) == 0 {
+		t = NewTransaction(randString(), randString(), types.Amount(r.Int63()-r.Int63()))
+	} else {
+		inputs := make([]types.OutPoint, r.Intn(4))
+		for i := range inputs {
+			r.Read(inputs[i].TxID[:])
+			inputs[i].Index = r.Intn(8)
+		}
+		outputs := make([]types.TxOutput, 1+r.Intn(3))
+		for i := range outputs {
+			outputs[i] = types.TxOutput{Address: randString(), Value: types.Amount(r.Int63() - r.Int63())}
+		}
+		t = NewSignedUTXOTransaction(randString(), inputs, outputs, 0, 0, nil, nil)
+	}
+	t.fee = types.Amount(r.Int63() - r.Int63())
+	t.nonce = r.Uint64()
+	if r.Intn(2) == 0 {
+		k := make([]byte, 32)
+		r.Read(k)
+		k[31] |= 1 // zero would give the point at infinity
+		x, y := elliptic.P256().ScalarBaseMult(k)
+		t.senderPublicKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		t.signature = &globals.Signature{R: randInt256(), S: randInt256()}
+	}
+	return t
+}
+
+func (t *Transaction) Generate(r *rand.Rand, _ int) reflect.Value {
+	return reflect.ValueOf(randomTransaction(r))
+}
+
+func TestTransaction_UnmarshalJSON(t *testing.T) {
+	Convey("transactions round-trip through JSON", t, func() {
+		property := func(original *Transaction) bool {
+			m, err := json.Marshal(original)
+			if err != nil {
+				return false
+			}
+			decoded := new(Transaction)
+			if err := json.Unmarshal(m, decoded); err != nil {
+				return false
+			}
+			again, _ := json.Marshal(decoded)
+			return bytes.Equal(m, again) && decoded.ID() == original.ID()
+		}
+		So(quick.Check(property, nil), ShouldBeNil)
+	})
+
+	Convey("malformed transactions are rejected", t, func() {
+		offCurve := strings.Repeat("0", 63) + "1" + strings.Repeat("0", 63) + "1"
+		for _, data := range []string{
+			`{}`,
+			`{"sender_blockchain_address":"A","recipient_blockchain_address":"B"}`,
+			`{"sender_blockchain_address":"A","recipient_blockchain_address":"B","value":1,"signature":"abc"}`,
+			`{"sender_blockchain_address":"A","recipient_blockchain_address":"B","value":1,"sender_public_key":"zz"}`,
+			// (1, 1) isn't on P-256
+			`{"sender_blockchain_address":"A","recipient_blockchain_address":"B","value":1,"sender_public_key":"` + offCurve + `"}`,
+		} {
+			So(json.Unmarshal([]byte(data), new(Transaction)), ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// LedgerMode selects how a chain keeps track of who owns which coins.
+type LedgerMode int
+
+const (
+	// AccountMode keeps a balance per address; transfers move value from
+	// the sender's balance to the recipient's.
+	AccountMode LedgerMode = iota
+	// UTXOMode keeps the set of unspent outputs; transactions spend outputs
+	// of their sender and create new ones.
+	UTXOMode
+)
+
+var (
+	ErrLedgerMode        = errors.New("transaction doesn't fit the chain's ledger mode")
+	ErrUnknownInput      = errors.New("transaction spends an output that doesn't exist or is already spent")
+	ErrForeignInput      = errors.New("transaction spends an output of another address")
+	ErrInputsMismatch    = errors.New("transaction inputs don't add up to its outputs plus fee")
+	ErrInvalidOutput     = errors.New("transaction output is invalid")
+	ErrUnknownLedgerMode = errors.New("unknown ledger mode")
+)
+
+func (m LedgerMode) String() string {
+	switch m {
+	case AccountMode:
+		return "account"
+	case UTXOMode:
+		return "utxo"
+	default:
+		return fmt.Sprintf("LedgerMode(%d)", int(m))
+	}
+}
+
+// ParseLedgerMode reads the names String returns.
+func ParseLedgerMode(s string) (LedgerMode, error) {
+	switch s {
+	case "account":
+		return AccountMode, nil
+	case "utxo":
+		return UTXOMode, nil
+	default:
+		return 0, fmt.Errorf("%w %q", ErrUnknownLedgerMode, s)
+	}
+}
+
+// UnspentOutputsResponse answers GET /address/{addr}/utxos.
+type UnspentOutputsResponse struct {
+	Address string                `json:"address"`
+	Outputs []types.UnspentOutput `json:"outputs"`
+}
+
+// fitsLedger checks that t has the form the mode asks for: UTXO transactions
+// in UTXOMode, transfers between accounts otherwise.
+func fitsLedger(t *Transaction, mode LedgerMode) error {
+	if t.spendsOutputs() != (mode == UTXOMode) {
+		return ErrLedgerMode
+	}
+	if !t.validOutputs() {
+		return ErrInvalidOutput
+	}
+	return nil
+}
+
+// checkInputs verifies that t spends outputs unspent finds that belong to its
+// sender, each once and none of them immature, and that they add up to
+// exactly what t pays out plus its fee.
+func checkInputs(t *Transaction, unspent func(op types.OutPoint) (types.TxOutput, bool), immature map[types.OutPoint]bool) error {
+	var total types.Amount
+	seen := make(map[types.OutPoint]bool)
+	for _, in := range t.inputs {
+		out, ok := unspent(in)
+		if !ok || seen[in] {
+			return ErrUnknownInput
+		}
+		seen[in] = true
+		if out.Address != t.senderBlockchainAddress {
+			return ErrForeignInput
+		}
+		if immature[in] {
+			return ErrImmatureCoinbase
+		}
+		var err error
+		if total, err = total.Add(out.Value); err != nil {
+			return ErrInputsMismatch
+		}
+	}
+	cost, err := t.cost()
+	if err != nil || total != cost {
+		return ErrInputsMismatch
+	}
+	return nil
+}
+
+// Mode is the ledger mode the chain was created with.
+func (bc *Blockchain) Mode() LedgerMode {
+	return bc.mode
+}
+
+// UnspentOutputs lists the confirmed outputs of address that can be spent
+// and no pending transaction spends yet, ordered by outpoint. It is empty in
+// AccountMode.
+func (bc *Blockchain) UnspentOutputs(address string) *UnspentOutputsResponse {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	immature := immatureOutPoints(bc.genesis.immatureCoinbases(bc.chain))
+	r := &UnspentOutputsResponse{Address: address, Outputs: []types.UnspentOutput{}}
+	for op, out := range bc.utxos {
+		if out.Address == address && !immature[op] && !bc.transactionPool.Spends(op) {
+			r.Outputs = append(r.Outputs, types.UnspentOutput{OutPoint: op, TxOutput: out})
+		}
+	}
+	sort.Slice(r.Outputs, func(i, j int) bool {
+		a, b := r.Outputs[i].OutPoint, r.Outputs[j].OutPoint
+		if c := bytes.Compare(a.TxID[:], b.TxID[:]); c != 0 {
+			return c < 0
+		}
+		return a.Index < b.Index
+	})
+	return r
+}
+
+// unspentOutput looks op up in the UTXO set. Callers must hold bc.mux.
+func (bc *Blockchain) unspentOutput(op types.OutPoint) (types.TxOutput, bool) {
+	out, ok := bc.utxos[op]
+	return out, ok
+}
+
+// connectOutputs moves the outputs t spends out of the UTXO set and the ones
+// it creates in. Callers must hold bc.mux.
+func (bc *Blockchain) connectOutputs(t *Transaction) {
+	for _, in := range t.inputs {
+		delete(bc.utxos, in)
+	}
+	id := t.ID()
+	for i, o := range t.Outputs() {
+		bc.utxos[types.OutPoint{TxID: id, Index: i}] = o
+	}
+}
+
+// disconnectOutputs undoes connectOutputs for t, which must be the last
+// transaction connected. The outputs it spent are looked up in the chain.
+// Callers must hold bc.mux.
+func (bc *Blockchain) disconnectOutputs(t *Transaction) {
+	id := t.ID()
+	for i := range t.Outputs() {
+		delete(bc.utxos, types.OutPoint{TxID: id, Index: i})
+	}
+	for _, in := range t.inputs {
+		if out, ok := bc.output(in); ok {
+			bc.utxos[in] = out
+		}
+	}
+}
+
+// output finds the output op names among the mined transactions. Callers
+// must hold bc.mux.
+func (bc *Blockchain) output(op types.OutPoint) (types.TxOutput, bool) {
+	height, ok := bc.transactionIndex[op.TxID]
+	if !ok {
+		return types.TxOutput{}, false
+	}
+	for _, t := range bc.chain[height].transactions {
+		if t.ID() == op.TxID {
+			if outputs := t.Outputs(); op.Index < len(outputs) {
+				return outputs[op.Index], true
+			}
+		}
+	}
+	return types.TxOutput{}, false
+}
@@ -0,0 +1,198 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/wallet"
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// addPayment pays value from the wallet's unspent outputs to recipient.
+func addPayment(bc *Blockchain, from *wallet.Wallet, to string, value types.Amount, fee types.Amount) (*Transaction, error) {
+	available := bc.UnspentOutputs(from.BlockchainAddress()).Outputs
+	nonce := bc.Nonce(from.BlockchainAddress()).Nonce
+	p, err := wallet.NewPayment(from.PrivateKey(), from.PublicKey(), from.BlockchainAddress(), to, value, fee, nonce, available)
+	if err != nil {
+		return nil, err
+	}
+	return addUTXOTransaction(bc, from, p.Inputs(), p.Outputs(), fee, nonce)
+}
+
+func addUTXOTransaction(bc *Blockchain, from *wallet.Wallet, inputs []types.OutPoint, outputs []types.TxOutput, fee types.Amount, nonce uint64) (*Transaction, error) {
+	signed := wallet.NewUTXOTransaction(from.PrivateKey(), from.PublicKey(), from.BlockchainAddress(), inputs, outputs, fee, nonce)
+	signature := signed.GenerateSignature()
+	t := NewSignedUTXOTransaction(from.BlockchainAddress(), inputs, outputs, fee, nonce, from.PublicKey(), signature)
+	return t, bc.AddUTXOTransaction(from.BlockchainAddress(), inputs, outputs, fee, nonce, from.PublicKey(), signature)
+}
+
+func TestBlockchain_UTXO(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	g := testGenesis()
+	g.Allocations = []Allocation{{Address: walletA.BlockchainAddress(), Amount: 10 * types.Coin}}
+
+	Convey("a payment spends outputs and returns the change", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, UTXOMode)
+		So(err, ShouldBeNil)
+		allocation := bc.UnspentOutputs(walletA.BlockchainAddress()).Outputs
+		So(allocation, ShouldHaveLength, 1)
+		So(allocation[0].Value, ShouldEqual, 10*types.Coin)
+
+		p, err := addPayment(bc, walletA, walletB.BlockchainAddress(), 3*types.Coin, types.Coin/10)
+		So(err, ShouldBeNil)
+		So(p.Outputs(), ShouldResemble, []types.TxOutput{
+			{Address: walletB.BlockchainAddress(), Value: 3 * types.Coin},
+			{Address: walletA.BlockchainAddress(), Value: 7*types.Coin - types.Coin/10},
+		})
+		// the pending payment has taken the allocation
+		So(bc.UnspentOutputs(walletA.BlockchainAddress()).Outputs, ShouldBeEmpty)
+
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.ValidChain(bc.chain), ShouldBeNil)
+		So(bc.CalculateTotalAmount(walletA.BlockchainAddress()), ShouldEqual, 7*types.Coin-types.Coin/10)
+		So(bc.CalculateTotalAmount(walletB.BlockchainAddress()), ShouldEqual, 3*types.Coin)
+		So(bc.UnspentOutputs(walletB.BlockchainAddress()).Outputs, ShouldResemble, []types.UnspentOutput{{
+			OutPoint: types.OutPoint{TxID: p.ID(), Index: 0},
+			TxOutput: types.TxOutput{Address: walletB.BlockchainAddress(), Value: 3 * types.Coin},
+		}})
+
+		history, err := bc.AddressHistory(walletB.BlockchainAddress(), "")
+		So(err, ShouldBeNil)
+		So(history.Transactions, ShouldHaveLength, 1)
+	})
+
+	Convey("coin selection combines outputs when none covers the amount alone", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), UTXOMode)
+		So(err, ShouldBeNil)
+		bc.SetBlockchainAddress(walletA.BlockchainAddress())
+		for i := 0; i < 3; i++ {
+			So(mineBlock(bc), ShouldBeTrue)
+		}
+		p, err := addPayment(bc, walletA, walletB.BlockchainAddress(), 2*MiningReward+MiningReward/2, 0)
+		So(err, ShouldBeNil)
+		So(p.Inputs(), ShouldHaveLength, 3)
+		So(p.Outputs()[1].Value, ShouldEqual, MiningReward/2)
+
+		_, err = addPayment(bc, walletA, walletB.BlockchainAddress(), MiningReward, 0)
+		So(errors.Is(err, wallet.ErrInsufficientFunds), ShouldBeTrue)
+	})
+
+	Convey("an output can only be spent once", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, UTXOMode)
+		So(err, ShouldBeNil)
+		allocation := bc.UnspentOutputs(walletA.BlockchainAddress()).Outputs[0].OutPoint
+		pay := func(nonce uint64) error {
+			_, err := addUTXOTransaction(bc, walletA, []types.OutPoint{allocation},
+				[]types.TxOutput{{Address: walletB.BlockchainAddress(), Value: 10 * types.Coin}}, 0, nonce)
+			return err
+		}
+		So(pay(0), ShouldBeNil)
+		So(errors.Is(pay(1), ErrInputInUse), ShouldBeTrue)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(errors.Is(pay(1), ErrUnknownInput), ShouldBeTrue)
+	})
+
+	Convey("inputs must belong to the sender and match the outputs", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, UTXOMode)
+		So(err, ShouldBeNil)
+		allocation := bc.UnspentOutputs(walletA.BlockchainAddress()).Outputs[0].OutPoint
+
+		_, err = addUTXOTransaction(bc, walletB, []types.OutPoint{allocation},
+			[]types.TxOutput{{Address: walletB.BlockchainAddress(), Value: 10 * types.Coin}}, 0, 0)
+		So(errors.Is(err, ErrForeignInput), ShouldBeTrue)
+
+		_, err = addUTXOTransaction(bc, walletA, []types.OutPoint{allocation},
+			[]types.TxOutput{{Address: walletB.BlockchainAddress(), Value: 9 * types.Coin}}, 0, 0)
+		So(errors.Is(err, ErrInputsMismatch), ShouldBeTrue)
+
+		_, err = addUTXOTransaction(bc, walletA, []types.OutPoint{allocation, allocation},
+			[]types.TxOutput{{Address: walletB.BlockchainAddress(), Value: 20 * types.Coin}}, 0, 0)
+		So(errors.Is(err, ErrUnknownInput), ShouldBeTrue)
+
+		_, err = addUTXOTransaction(bc, walletA, []types.OutPoint{allocation},
+			[]types.TxOutput{{Address: walletB.BlockchainAddress(), Value: 11 * types.Coin}, {Address: walletA.BlockchainAddress(), Value: -types.Coin}}, 0, 0)
+		So(errors.Is(err, ErrInvalidOutput), ShouldBeTrue)
+
+		// claiming walletA's address doesn't make its outputs the thief's
+		thief := wallet.NewWallet()
+		inputs := []types.OutPoint{allocation}
+		outputs := []types.TxOutput{{Address: thief.BlockchainAddress(), Value: 10 * types.Coin}}
+		signed := wallet.NewUTXOTransaction(thief.PrivateKey(), thief.PublicKey(), walletA.BlockchainAddress(), inputs, outputs, 0, 0)
+		err = bc.AddUTXOTransaction(walletA.BlockchainAddress(), inputs, outputs, 0, 0, thief.PublicKey(), signed.GenerateSignature())
+		So(errors.Is(err, ErrSenderMismatch), ShouldBeTrue)
+	})
+
+	Convey("transactions have to fit the ledger mode", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, UTXOMode)
+		So(err, ShouldBeNil)
+		err = addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin)
+		So(errors.Is(err, ErrLedgerMode), ShouldBeTrue)
+
+		accounts, err := NewBlockchain(gl, NewMemoryStorage(), g, AccountMode)
+		So(err, ShouldBeNil)
+		allocation := bc.UnspentOutputs(walletA.BlockchainAddress()).Outputs[0].OutPoint
+		_, err = addUTXOTransaction(accounts, walletA, []types.OutPoint{allocation},
+			[]types.TxOutput{{Address: walletB.BlockchainAddress(), Value: 10 * types.Coin}}, 0, 0)
+		So(errors.Is(err, ErrLedgerMode), ShouldBeTrue)
+	})
+
+	Convey("a block spending a spent output is invalid", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, UTXOMode)
+		So(err, ShouldBeNil)
+		allocation := bc.UnspentOutputs(walletA.BlockchainAddress()).Outputs[0].OutPoint
+		outputs := []types.TxOutput{{Address: walletB.BlockchainAddress(), Value: 10 * types.Coin}}
+		_, err = addUTXOTransaction(bc, walletA, []types.OutPoint{allocation}, outputs, 0, 0)
+		So(err, ShouldBeNil)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(mineBlock(bc), ShouldBeTrue)
+
+		signed := wallet.NewUTXOTransaction(walletA.PrivateKey(), walletA.PublicKey(), walletA.BlockchainAddress(), []types.OutPoint{allocation}, outputs, 0, 1)
+		again := NewSignedUTXOTransaction(walletA.BlockchainAddress(), []types.OutPoint{allocation}, outputs, 0, 1, walletA.PublicKey(), signed.GenerateSignature())
+		chain := append([]*Block{}, bc.chain...)
+		tampered := *chain[2]
+		tampered.transactions = append([]*Transaction{again}, tampered.transactions...)
+		chain[2] = &tampered
+		So(errors.Is(bc.ValidChain(chain), ErrUnknownInput), ShouldBeTrue)
+	})
+
+	Convey("a block spending an output twice doesn't get on the chain", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, UTXOMode)
+		So(err, ShouldBeNil)
+		allocation := bc.UnspentOutputs(walletA.BlockchainAddress()).Outputs[0].OutPoint
+		var transactions []*Transaction
+		for nonce, to := range []*wallet.Wallet{walletB, walletA} {
+			outputs := []types.TxOutput{{Address: to.BlockchainAddress(), Value: 10 * types.Coin}}
+			signed := wallet.NewUTXOTransaction(walletA.PrivateKey(), walletA.PublicKey(), walletA.BlockchainAddress(), []types.OutPoint{allocation}, outputs, 0, uint64(nonce))
+			transactions = append(transactions, NewSignedUTXOTransaction(walletA.BlockchainAddress(), []types.OutPoint{allocation}, outputs, 0, uint64(nonce), walletA.PublicKey(), signed.GenerateSignature()))
+		}
+		b := NewBlock(0, bc.LastBlock().Hash(), BlockTimestamp, bc.nextBits(bc.chain), transactions)
+		b.nonce, err = NewMiner(1).Solve(context.Background(), b)
+		So(err, ShouldBeNil)
+
+		So(errors.Is(bc.AppendBlock(b), ErrUnknownInput), ShouldBeTrue)
+		So(bc.chain, ShouldHaveLength, 1)
+		So(bc.UnspentOutputs(walletA.BlockchainAddress()).Outputs, ShouldHaveLength, 1)
+	})
+
+	Convey("disconnecting blocks restores the outputs they spent", t, func() {
+		bc, err := NewBlockchain(gl, NewMemoryStorage(), g, UTXOMode)
+		So(err, ShouldBeNil)
+		before := bc.UnspentOutputs(walletA.BlockchainAddress())
+		_, err = addPayment(bc, walletA, walletB.BlockchainAddress(), 4*types.Coin, 0)
+		So(err, ShouldBeNil)
+		So(mineBlock(bc), ShouldBeTrue)
+		So(bc.UnspentOutputs(walletB.BlockchainAddress()).Outputs, ShouldHaveLength, 1)
+
+		bc.mux.Lock()
+		bc.truncateChain(1)
+		bc.mux.Unlock()
+		So(bc.UnspentOutputs(walletA.BlockchainAddress()), ShouldResemble, before)
+		So(bc.UnspentOutputs(walletB.BlockchainAddress()).Outputs, ShouldBeEmpty)
+		So(bc.utxos, ShouldHaveLength, 1)
+	})
+}
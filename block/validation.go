@@ -0,0 +1,309 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/wallet"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEmptyChain        = errors.New("chain has no blocks")
+	ErrInvalidGenesis    = errors.New("genesis block does not match the network's genesis")
+	ErrGenesisMismatch   = errors.New("neighbor runs a different genesis block")
+	ErrPreviousHash      = errors.New("previous hash does not match the hash of the preceding block")
+	ErrInvalidProof      = errors.New("block hash does not meet its target")
+	ErrInvalidDifficulty = errors.New("block declares the wrong target")
+	ErrInvalidTimestamp  = errors.New("block timestamp is before the median of its predecessors or too far in the future")
+	ErrInvalidSignature  = errors.New("transaction signature is missing or invalid")
+	ErrSenderMismatch    = errors.New("sender address does not belong to the signing public key")
+	ErrInvalidCoinbase   = errors.New("coinbase transaction is invalid")
+	ErrDuplicateCoinbase = errors.New("block has more than one coinbase transaction")
+	ErrKnownBlock        = errors.New("block is already in the chain")
+	ErrUnknownParent     = errors.New("block does not extend the last block")
+
+	ErrReservedSender       = errors.New("sender address is reserved for mining rewards")
+	ErrInvalidValue         = errors.New("transaction value must be positive")
+	ErrInvalidFee           = errors.New("transaction fee must not be negative")
+	ErrTooManyTransactions  = errors.New("block holds more transactions than allowed")
+	ErrDuplicateTransaction = errors.New("transaction has already been submitted")
+	ErrInvalidNonce         = errors.New("transaction nonce is not the next one of its sender")
+	ErrNonceTooLow          = errors.New("transaction nonce has already been used")
+	ErrInsufficientBalance  = errors.New("sender balance is too low")
+)
+
+// ChainError names the first block that failed validation and why.
+type ChainError struct {
+	Height int
+	Hash   types.Byte32
+	Err    error
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("invalid block %d (%x): %v", e.Height, e.Hash, e.Err)
+}
+
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+// ValidChain walks chain from the genesis block and returns a *ChainError for
+// the first block whose linkage, proof of work or transactions don't check out.
+func (bc *Blockchain) ValidChain(chain []*Block) error {
+	if len(chain) == 0 {
+		return ErrEmptyChain
+	}
+
+	genesis := chain[0]
+	if hash := genesis.Hash(); hash != bc.genesisHash {
+		return &ChainError{Height: 0, Hash: hash, Err: ErrInvalidGenesis}
+	}
+
+	l := ledgerOf(chain[:1], bc.mode)
+	for height := 1; height < len(chain); height++ {
+		b := chain[height]
+		if err := bc.validBlock(b, chain[:height], l); err != nil {
+			return &ChainError{Height: height, Hash: b.Hash(), Err: err}
+		}
+	}
+	return nil
+}
+
+// validBlock checks a single non-genesis block against the chain it extends
+// and records its transactions in l, which must hold that chain.
+func (bc *Blockchain) validBlock(b *Block, chain []*Block, l *ledger) error {
+	if b.previousHash != chain[len(chain)-1].Hash() {
+		return ErrPreviousHash
+	}
+	maxTime := bc.globals.NowUnixNano() + int64(MaxFutureBlockTime)
+	if b.timestamp < medianTime(chain) || b.timestamp > maxTime {
+		return ErrInvalidTimestamp
+	}
+	if b.bits != bc.nextBits(chain) {
+		return ErrInvalidDifficulty
+	}
+	if len(b.transactions) > MaxBlockTransactions {
+		return ErrTooManyTransactions
+	}
+	if err := bc.validTransactions(b.transactions, chain, l); err != nil {
+		return err
+	}
+	if !b.ValidProof() {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// verifySender checks that t is signed by its sender: the signature has to
+// verify against the public key, and the sender address has to be the one
+// derived from that key. Otherwise anyone could sign with their own key and
+// spend from somebody else's address.
+func (bc *Blockchain) verifySender(t *Transaction) error {
+	if !bc.VerifyTransactionSignature(t.senderPublicKey, t.signature, t) {
+		return ErrInvalidSignature
+	}
+	if wallet.AddressFromPublicKey(t.senderPublicKey) != t.senderBlockchainAddress {
+		return ErrSenderMismatch
+	}
+	return nil
+}
+
+// validTransactions checks the transactions of the block extending chain
+// against l and records them. Transfers must be signed by the key their
+// sender address was derived from. Every sender's nonces must follow on from
+// the ones the chain has seen. In UTXOMode transfers must spend unspent
+// outputs of their sender instead of drawing on a balance. Neither may touch
+// coinbases that haven't matured yet. The coinbase, if any, must carry the
+// block height as its nonce and pay exactly the scheduled reward plus the
+// block's fees.
+func (bc *Blockchain) validTransactions(transactions []*Transaction, chain []*Block, l *ledger) error {
+	height := len(chain)
+	issued := l.supply
+	immature := bc.genesis.immatureCoinbases(chain)
+	immatureAmount, immatureOutput := immatureAmounts(immature), immatureOutPoints(immature)
+	var coinbase *Transaction
+	fees := types.Amount(0)
+	for _, t := range transactions {
+		if t.senderBlockchainAddress == MiningSender {
+			if coinbase != nil {
+				return ErrDuplicateCoinbase
+			}
+			if t.fee != 0 || t.nonce != uint64(height) || t.spendsOutputs() {
+				return ErrInvalidCoinbase
+			}
+			coinbase = t
+			if err := l.record(t); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := bc.verifySender(t); err != nil {
+			return err
+		}
+		if t.value <= 0 {
+			return ErrInvalidValue
+		}
+		if err := fitsLedger(t, bc.mode); err != nil {
+			return err
+		}
+		if t.fee < 0 {
+			return ErrInvalidFee
+		}
+		cost, err := t.cost()
+		if err != nil {
+			return ErrInvalidValue
+		}
+		if l.hasTransaction(t.ID()) {
+			return ErrDuplicateTransaction
+		}
+		if t.nonce != l.nonce(t.senderBlockchainAddress) {
+			return ErrInvalidNonce
+		}
+		if bc.mode == UTXOMode {
+			if err := checkInputs(t, l.unspent, immatureOutput); err != nil {
+				return err
+			}
+		} else if balance := l.balance(t.senderBlockchainAddress); balance < cost {
+			return ErrInsufficientBalance
+		} else if balance-immatureAmount[t.senderBlockchainAddress] < cost {
+			return ErrImmatureCoinbase
+		}
+		if err := l.record(t); err != nil {
+			return err
+		}
+		if fees, err = fees.Add(t.fee); err != nil {
+			return err
+		}
+	}
+	if coinbase != nil {
+		reward, err := bc.genesis.Reward(height, issued).Add(fees)
+		if err != nil || coinbase.value != reward {
+			return ErrInvalidCoinbase
+		}
+	}
+	return nil
+}
+
+// ledger is the state a chain has built up to some block: which transactions
+// it already holds, what every address owns, which nonce it is at and how
+// many coins have been issued. In UTXOMode it also holds the unspent outputs.
+// A ledger on top of a Blockchain's tip only keeps what changed since the
+// tip and reads everything else from the Blockchain.
+type ledger struct {
+	transactionIDs map[types.Byte32]bool
+	balances       map[string]types.Amount
+	nonces         map[string]uint64
+	supply         types.Amount
+	utxos          map[types.OutPoint]types.TxOutput
+	// tip is read through to and never written; spent holds its outputs
+	// this ledger spent
+	tip   *Blockchain
+	spent map[types.OutPoint]bool
+}
+
+func newLedger(mode LedgerMode) *ledger {
+	l := &ledger{
+		transactionIDs: make(map[types.Byte32]bool),
+		balances:       make(map[string]types.Amount),
+		nonces:         make(map[string]uint64),
+	}
+	if mode == UTXOMode {
+		l.utxos = make(map[types.OutPoint]types.TxOutput)
+	}
+	return l
+}
+
+func (l *ledger) hasTransaction(id types.Byte32) bool {
+	if l.transactionIDs[id] {
+		return true
+	}
+	if l.tip != nil {
+		_, ok := l.tip.transactionIndex[id]
+		return ok
+	}
+	return false
+}
+
+func (l *ledger) balance(address string) types.Amount {
+	if balance, ok := l.balances[address]; ok || l.tip == nil {
+		return balance
+	}
+	return l.tip.balances[address]
+}
+
+func (l *ledger) nonce(address string) uint64 {
+	if nonce, ok := l.nonces[address]; ok || l.tip == nil {
+		return nonce
+	}
+	return l.tip.nonces[address]
+}
+
+// unspent looks op up among the unspent outputs.
+func (l *ledger) unspent(op types.OutPoint) (types.TxOutput, bool) {
+	if out, ok := l.utxos[op]; ok || l.tip == nil || l.spent[op] {
+		return out, ok
+	}
+	return l.tip.unspentOutput(op)
+}
+
+// record applies t to l. A ledger that returned an error must be discarded.
+func (l *ledger) record(t *Transaction) error {
+	cost, err := t.cost()
+	if err != nil {
+		return err
+	}
+	sent, err := l.balance(t.senderBlockchainAddress).Sub(cost)
+	if err != nil {
+		return err
+	}
+	l.balances[t.senderBlockchainAddress] = sent
+	for _, o := range t.Outputs() {
+		received, err := l.balance(o.Address).Add(o.Value)
+		if err != nil {
+			return err
+		}
+		l.balances[o.Address] = received
+	}
+	if l.utxos != nil {
+		for _, in := range t.inputs {
+			delete(l.utxos, in)
+			if l.tip != nil {
+				l.spent[in] = true
+			}
+		}
+		id := t.ID()
+		for i, o := range t.Outputs() {
+			l.utxos[types.OutPoint{TxID: id, Index: i}] = o
+		}
+	}
+	if t.senderBlockchainAddress != MiningSender {
+		l.transactionIDs[t.ID()] = true
+		l.nonces[t.senderBlockchainAddress] = t.nonce + 1
+		l.supply -= t.fee
+	} else {
+		l.supply += t.value
+	}
+	return nil
+}
+
+// tipLedger is the ledger of bc.chain, built from the state bc keeps for its
+// tip rather than by replaying the chain. Callers must hold bc.mux and must
+// not change the chain while the ledger is in use.
+func (bc *Blockchain) tipLedger() *ledger {
+	l := newLedger(bc.mode)
+	l.tip = bc
+	l.spent = make(map[types.OutPoint]bool)
+	l.supply = bc.chainSupply[len(bc.chain)-1]
+	return l
+}
+
+// ledgerOf records chain without validating it again.
+func ledgerOf(chain []*Block, mode LedgerMode) *ledger {
+	l := newLedger(mode)
+	for _, b := range chain {
+		for _, t := range b.transactions {
+			_ = l.record(t)
+		}
+	}
+	return l
+}
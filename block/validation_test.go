@@ -0,0 +1,201 @@
+package block
+
+import (
+	types "blockchain/blockchaintypes"
+	"blockchain/mock_main"
+	"blockchain/wallet"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func testGenesis() *Genesis {
+	return &Genesis{
+		NetworkID:        "testnet",
+		Timestamp:        BlockTimestamp,
+		Difficulty:       MiningDifficulty,
+		RetargetInterval: DefaultRetargetInterval,
+		BlockTime:        MiningTimerSec,
+		Allocations:      []Allocation{},
+	}
+}
+
+// testGlobals mocks the globals a Blockchain reads. Its clock reads
+// BlockTimestamp unless now is given.
+func testGlobals(t *testing.T, now func() int64) *mock_main.MockIGlobalLib {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+	if now == nil {
+		now = func() int64 { return BlockTimestamp }
+	}
+	gl := mock_main.NewMockIGlobalLib(ctrl)
+	gl.EXPECT().EmptyByte32().AnyTimes().Return(types.Byte32{})
+	gl.EXPECT().NowUnixNano().AnyTimes().DoAndReturn(now)
+	return gl
+}
+
+func addSignedTransaction(bc *Blockchain, from *wallet.Wallet, to string, value types.Amount) error {
+	return addSignedTransactionWithFee(bc, from, to, value, 0)
+}
+
+func addSignedTransactionWithFee(bc *Blockchain, from *wallet.Wallet, to string, value types.Amount, fee types.Amount) error {
+	return addSignedTransactionWithNonce(bc, from, to, value, fee, bc.Nonce(from.BlockchainAddress()).Nonce)
+}
+
+func addSignedTransactionWithNonce(bc *Blockchain, from *wallet.Wallet, to string, value types.Amount, fee types.Amount, nonce uint64) error {
+	t := wallet.NewTransaction(from.PrivateKey(), from.PublicKey(), from.BlockchainAddress(), to, value, fee, nonce)
+	return bc.AddTransaction(from.BlockchainAddress(), to, value, fee, nonce, from.PublicKey(), t.GenerateSignature())
+}
+
+func TestBlockchain_ValidChain(t *testing.T) {
+	gl := testGlobals(t, nil)
+
+	bc, err := NewBlockchain(gl, NewMemoryStorage(), testGenesis(), AccountMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+
+	bc.SetBlockchainAddress(walletA.BlockchainAddress())
+	mineBlock(bc)
+	addSignedTransaction(bc, walletA, walletB.BlockchainAddress(), types.Coin)
+	mineBlock(bc)
+	addSignedTransaction(bc, walletB, walletA.BlockchainAddress(), types.Coin/2)
+	mineBlock(bc)
+
+	Convey("a mined chain is valid", t, func() {
+		So(len(bc.chain), ShouldEqual, 4)
+		So(bc.ValidChain(bc.chain), ShouldBeNil)
+	})
+
+	Convey("an empty chain is rejected", t, func() {
+		So(errors.Is(bc.ValidChain(nil), ErrEmptyChain), ShouldBeTrue)
+	})
+
+	Convey("tampering is reported against the first bad block", t, func() {
+		copyChain := func() []*Block {
+			chain := make([]*Block, len(bc.chain))
+			for i, b := range bc.chain {
+				c := *b
+				chain[i] = &c
+			}
+			return chain
+		}
+
+		Convey("a broken previous hash", func() {
+			chain := copyChain()
+			chain[3].previousHash = types.Byte32{1}
+			var ce *ChainError
+			err := bc.ValidChain(chain)
+			So(errors.As(err, &ce), ShouldBeTrue)
+			So(ce.Height, ShouldEqual, 3)
+			So(errors.Is(err, ErrPreviousHash), ShouldBeTrue)
+		})
+
+		Convey("a nonce that doesn't solve the block", func() {
+			chain := copyChain()
+			chain[2].nonce++
+			var ce *ChainError
+			err := bc.ValidChain(chain)
+			So(errors.As(err, &ce), ShouldBeTrue)
+			So(ce.Height, ShouldEqual, 2)
+			So(errors.Is(err, ErrInvalidProof), ShouldBeTrue)
+		})
+
+		Convey("a block declaring an easier target", func() {
+			chain := copyChain()
+			chain[3].bits = TargetToCompact(maxTarget)
+			So(errors.Is(bc.ValidChain(chain), ErrInvalidDifficulty), ShouldBeTrue)
+		})
+
+		Convey("a timestamp behind its predecessors or far ahead", func() {
+			chain := copyChain()
+			chain[3].timestamp = BlockTimestamp - 1
+			So(errors.Is(bc.ValidChain(chain), ErrInvalidTimestamp), ShouldBeTrue)
+			chain[3].timestamp = BlockTimestamp + int64(MaxFutureBlockTime) + 1
+			So(errors.Is(bc.ValidChain(chain), ErrInvalidTimestamp), ShouldBeTrue)
+		})
+
+		Convey("a transaction value changed after signing", func() {
+			chain := copyChain()
+			tampered := *chain[2].transactions[0]
+			tampered.value = 100
+			chain[2].transactions = []*Transaction{&tampered, chain[2].transactions[1]}
+			var ce *ChainError
+			err := bc.ValidChain(chain)
+			So(errors.As(err, &ce), ShouldBeTrue)
+			So(ce.Height, ShouldEqual, 2)
+			So(errors.Is(err, ErrInvalidSignature), ShouldBeTrue)
+		})
+
+		Convey("a transfer signed by a key its sender address doesn't belong to", func() {
+			chain := copyChain()
+			thief := wallet.NewWallet()
+			signed := wallet.NewTransaction(thief.PrivateKey(), thief.PublicKey(), walletA.BlockchainAddress(), thief.BlockchainAddress(), types.Coin/10, 0, 1)
+			theft := NewSignedTransaction(walletA.BlockchainAddress(), thief.BlockchainAddress(), types.Coin/10, 0, 1, thief.PublicKey(), signed.GenerateSignature())
+			chain[3].transactions = append([]*Transaction{theft}, chain[3].transactions...)
+			var ce *ChainError
+			err := bc.ValidChain(chain)
+			So(errors.As(err, &ce), ShouldBeTrue)
+			So(ce.Height, ShouldEqual, 3)
+			So(errors.Is(err, ErrSenderMismatch), ShouldBeTrue)
+		})
+
+		Convey("a second coinbase transaction", func() {
+			chain := copyChain()
+			chain[3].transactions = append(chain[3].transactions, NewTransaction(MiningSender, "X", MiningReward))
+			So(errors.Is(bc.ValidChain(chain), ErrDuplicateCoinbase), ShouldBeTrue)
+		})
+
+		Convey("a coinbase paying more than the reward and fees", func() {
+			chain := copyChain()
+			coinbase := *chain[3].transactions[1]
+			coinbase.value++
+			chain[3].transactions = []*Transaction{chain[3].transactions[0], &coinbase}
+			So(errors.Is(bc.ValidChain(chain), ErrInvalidCoinbase), ShouldBeTrue)
+		})
+
+		Convey("a coinbase not numbered by its block's height", func() {
+			chain := copyChain()
+			coinbase := *chain[3].transactions[1]
+			coinbase.nonce = 2
+			chain[3].transactions = []*Transaction{chain[3].transactions[0], &coinbase}
+			So(errors.Is(bc.ValidChain(chain), ErrInvalidCoinbase), ShouldBeTrue)
+		})
+
+		Convey("a transfer skipping a nonce", func() {
+			chain := copyChain()
+			signed := wallet.NewTransaction(walletA.PrivateKey(), walletA.PublicKey(), walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/10, 0, 2)
+			skipping := NewSignedTransaction(walletA.BlockchainAddress(), walletB.BlockchainAddress(), types.Coin/10, 0, 2, walletA.PublicKey(), signed.GenerateSignature())
+			chain[3].transactions = append([]*Transaction{skipping}, chain[3].transactions...)
+			So(errors.Is(bc.ValidChain(chain), ErrInvalidNonce), ShouldBeTrue)
+		})
+
+		Convey("a block over the transaction limit", func() {
+			chain := copyChain()
+			for len(chain[3].transactions) <= MaxBlockTransactions {
+				chain[3].transactions = append([]*Transaction{chain[2].transactions[0]}, chain[3].transactions...)
+			}
+			So(errors.Is(bc.ValidChain(chain), ErrTooManyTransactions), ShouldBeTrue)
+		})
+
+		Convey("a transfer replayed in a later block", func() {
+			chain := copyChain()
+			chain[3].transactions = append([]*Transaction{chain[2].transactions[0]}, chain[3].transactions...)
+			So(errors.Is(bc.ValidChain(chain), ErrDuplicateTransaction), ShouldBeTrue)
+		})
+
+		Convey("a transfer the sender can't afford", func() {
+			chain := copyChain()
+			chain[1].transactions = chain[2].transactions[:1]
+			var ce *ChainError
+			err := bc.ValidChain(chain)
+			So(errors.As(err, &ce), ShouldBeTrue)
+			So(ce.Height, ShouldEqual, 1)
+			So(errors.Is(err, ErrInsufficientBalance), ShouldBeTrue)
+		})
+	})
+}
@@ -2,13 +2,22 @@ package main
 
 import (
 	"blockchain/block"
+	types "blockchain/blockchaintypes"
 	"blockchain/globals"
 	"blockchain/wallet"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+)
+
+const (
+	DefaultBlocksLimit = 20
+	MaxBlocksLimit     = 100
 )
 
 var gl = globals.NewGlobals()
@@ -58,13 +67,16 @@ func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Reque
 	case http.MethodGet:
 		w.Header().Add("Content-Type", "application/json")
 		bc := bcs.GetBlockchain()
+		stats := bc.MempoolStats()
 		transactions := bc.TransactionPool()
 		m, _ := json.Marshal(struct {
 			Transactions []*block.Transaction `json:"transactions"`
 			Length       int                  `json:"length"`
+			Stats        *block.MempoolStats  `json:"stats"`
 		}{
 			Transactions: transactions,
 			Length:       len(transactions),
+			Stats:        stats,
 		})
 		io.WriteString(w, string(m[:]))
 
@@ -79,33 +91,76 @@ func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Reque
 			io.WriteString(w, string(gl.JsonStatus("failed: decoding failed")))
 			return
 		}
-		if !t.Validate() {
-			log.Printf("ERROR: invalid payload")
+		if err := t.Validate(); err != nil {
+			log.Printf("ERROR: %v", err)
 			w.WriteHeader(http.StatusBadRequest)
-			io.WriteString(w, string(gl.JsonStatus("failed: invalid payload")))
+			io.WriteString(w, string(gl.JsonStatus(fmt.Sprintf("failed: %v", err))))
+			return
+		}
+		if err := t.ValidateAddresses(); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(gl.JsonStatus(fmt.Sprintf("failed: %v", err))))
+			return
 		}
 
 		publicKey := gl.PublicKeyFromString(*t.SenderPublicKey)
 		signature := gl.SignatureFromString(*t.Signature)
+		var fee types.Amount
+		if t.Fee != nil {
+			fee = *t.Fee
+		}
+		var nonce uint64
+		if t.Nonce != nil {
+			nonce = *t.Nonce
+		}
 
-		isCreated := bcs.blockchain.CreateTransaction(
-			*t.SenderBlockchainAddress,
-			*t.RecipientBlockchainAddress,
-			*t.Value,
-			publicKey,
-			signature,
-		)
+		var id types.Byte32
+		if len(t.Outputs) > 0 {
+			err = bcs.blockchain.CreateUTXOTransaction(
+				*t.SenderBlockchainAddress,
+				t.Inputs,
+				t.Outputs,
+				fee,
+				nonce,
+				publicKey,
+				signature,
+			)
+			id = block.NewSignedUTXOTransaction(*t.SenderBlockchainAddress, t.Inputs, t.Outputs, fee, nonce, nil, nil).ID()
+		} else {
+			err = bcs.blockchain.CreateTransaction(
+				*t.SenderBlockchainAddress,
+				*t.RecipientBlockchainAddress,
+				*t.Value,
+				fee,
+				nonce,
+				publicKey,
+				signature,
+			)
+			id = block.NewSignedTransaction(*t.SenderBlockchainAddress, *t.RecipientBlockchainAddress, *t.Value, fee, nonce, nil, nil).ID()
+		}
 
+		tr := &block.TransactionResponse{ID: fmt.Sprintf("%x", id)}
 		w.Header().Add("Content-Type", "application/json")
-		var m []byte
-		if !isCreated {
-			w.WriteHeader(http.StatusBadRequest)
-			m = gl.JsonStatus("fail")
-		} else {
+		switch {
+		case err == nil:
 			w.WriteHeader(http.StatusCreated)
-			m = gl.JsonStatus("success")
+			tr.Message = "success"
+		case errors.Is(err, block.ErrDuplicateTransaction),
+			errors.Is(err, block.ErrNonceTooLow),
+			errors.Is(err, block.ErrNonceInUse),
+			errors.Is(err, block.ErrInputInUse):
+			w.WriteHeader(http.StatusConflict)
+			tr.Message = fmt.Sprintf("failed: %v", err)
+		case errors.Is(err, block.ErrMempoolFull), errors.Is(err, block.ErrSenderPoolFull):
+			w.WriteHeader(http.StatusServiceUnavailable)
+			tr.Message = fmt.Sprintf("failed: %v", err)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			tr.Message = fmt.Sprintf("failed: %v", err)
 		}
-		io.WriteString(w, string(gl.JsonStatus(string(m))))
+		m, _ := json.Marshal(tr)
+		io.WriteString(w, string(m))
 
 		log.Printf("INFO: transaction_request: %+v", t)
 	default:
@@ -113,6 +168,51 @@ func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Reque
 	}
 }
 
+// Transaction reports the status of the transaction whose id follows
+// /transactions/ in the path, or with a trailing /proof its Merkle proof of
+// inclusion, in the block at ?height= if given.
+func (bcs *BlockchainServer) Transaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		path := strings.TrimPrefix(req.URL.Path, "/transactions/")
+		proof := strings.HasSuffix(path, "/proof")
+		id, err := types.ParseByte32(strings.TrimSuffix(path, "/proof"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(gl.JsonStatus(fmt.Sprintf("failed: %v", err))))
+			return
+		}
+		var v interface{}
+		var ok bool
+		switch {
+		case proof && req.URL.Query().Has("height"):
+			height, err := queryInt(req, "height", 0)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(gl.JsonStatus("failed: invalid height")))
+				return
+			}
+			v, ok = bcs.GetBlockchain().TransactionProofAt(id, height)
+		case proof:
+			// only mined transactions have a proof
+			v, ok = bcs.GetBlockchain().TransactionProof(id)
+		default:
+			v, ok = bcs.GetBlockchain().TransactionStatus(id)
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(gl.JsonStatus("failed: transaction not found")))
+			return
+		}
+		m, _ := json.Marshal(v)
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
 func (bcs *BlockchainServer) Mine(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
@@ -147,13 +247,31 @@ func (bcs *BlockchainServer) StartMine(w http.ResponseWriter, req *http.Request)
 	}
 }
 
+func (bcs *BlockchainServer) MiningStats(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		miner := bcs.GetBlockchain().Miner()
+		m, _ := json.Marshal(struct {
+			Workers  int     `json:"workers"`
+			HashRate float64 `json:"hash_rate"`
+		}{
+			Workers:  miner.Workers(),
+			HashRate: miner.HashRate(),
+		})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
 func (bcs *BlockchainServer) Amount(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
 		blockchainAddress := req.URL.Query().Get("blockchain_address")
 		amount := bcs.GetBlockchain().CalculateTotalAmount(blockchainAddress)
 
-		ar := &block.AmountResponse{amount}
+		ar := &block.AmountResponse{Amount: amount}
 		m, _ := ar.MarshalJSON()
 
 		w.Header().Add("Content-Type", "application/json")
@@ -164,17 +282,256 @@ func (bcs *BlockchainServer) Amount(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// Address serves /address/{address}/transactions?cursor=,
+// /address/{address}/nonce and /address/{address}/utxos.
+func (bcs *BlockchainServer) Address(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		address, rest, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/address/"), "/")
+		if address == "" || (rest != "transactions" && rest != "nonce" && rest != "utxos") {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(gl.JsonStatus("failed: not found")))
+			return
+		}
+		switch rest {
+		case "nonce":
+			m, _ := json.Marshal(bcs.GetBlockchain().Nonce(address))
+			io.WriteString(w, string(m[:]))
+			return
+		case "utxos":
+			m, _ := json.Marshal(bcs.GetBlockchain().UnspentOutputs(address))
+			io.WriteString(w, string(m[:]))
+			return
+		}
+		history, err := bcs.GetBlockchain().AddressHistory(address, req.URL.Query().Get("cursor"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(gl.JsonStatus(fmt.Sprintf("failed: %v", err))))
+			return
+		}
+		m, _ := json.Marshal(history)
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) Consensus(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPut:
+		bc := bcs.GetBlockchain()
+		replaced := bc.ResolveConflicts()
+
+		w.Header().Add("Content-Type", "application/json")
+		if replaced {
+			io.WriteString(w, string(gl.JsonStatus("success")))
+		} else {
+			io.WriteString(w, string(gl.JsonStatus("fail")))
+		}
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) Blocks(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		from, err := queryInt(req, "from", 0)
+		if err != nil || from < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(gl.JsonStatus("failed: invalid from")))
+			return
+		}
+		limit, err := queryInt(req, "limit", DefaultBlocksLimit)
+		if err != nil || limit < 1 || limit > MaxBlocksLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(gl.JsonStatus(fmt.Sprintf("failed: limit must be between 1 and %d", MaxBlocksLimit))))
+			return
+		}
+
+		bc := bcs.GetBlockchain()
+		blocks := bc.Blocks(from, limit)
+		m, _ := json.Marshal(struct {
+			Blocks []*block.BlockResponse `json:"blocks"`
+			Height int                    `json:"height"`
+		}{
+			Blocks: blocks,
+			Height: bc.Height(),
+		})
+		io.WriteString(w, string(m[:]))
+	case http.MethodPost:
+		var b block.Block
+		err := gl.DecodeJSONBody(w, req, &b)
+		if !gl.IsHttpOk(err, w) {
+			return
+		}
+
+		bc := bcs.GetBlockchain()
+		err = bc.AppendBlock(&b)
+		w.Header().Add("Content-Type", "application/json")
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, string(gl.JsonStatus("success")))
+			bc.AnnounceBlock(&b)
+		case errors.Is(err, block.ErrKnownBlock):
+			io.WriteString(w, string(gl.JsonStatus("known block")))
+		case errors.Is(err, block.ErrUnknownParent):
+			// the announcer is ahead of us, catch up with the network
+			bc.ResolveConflicts()
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, string(gl.JsonStatus("synchronized")))
+		default:
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(gl.JsonStatus(fmt.Sprintf("failed: %v", err))))
+		}
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Block serves /blocks/{height}, /blocks/hash/{hash} and /blocks/latest.
+func (bcs *BlockchainServer) Block(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockchain()
+		key := strings.TrimPrefix(req.URL.Path, "/blocks/")
+
+		var br *block.BlockResponse
+		found := true
+		switch {
+		case key == "latest":
+			br = bc.LatestBlock()
+		case strings.HasPrefix(key, "hash/"):
+			hash, err := types.ParseByte32(strings.TrimPrefix(key, "hash/"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(gl.JsonStatus(fmt.Sprintf("failed: %v", err))))
+				return
+			}
+			br, found = bc.BlockByHash(hash)
+		default:
+			height, err := strconv.Atoi(key)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(gl.JsonStatus("failed: invalid block height")))
+				return
+			}
+			br, found = bc.BlockByHeight(height)
+		}
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(gl.JsonStatus("failed: block not found")))
+			return
+		}
+		m, _ := json.Marshal(br)
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Headers serves block headers for light clients, paged like Blocks.
+func (bcs *BlockchainServer) Headers(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		from, err := queryInt(req, "from", 0)
+		if err != nil || from < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(gl.JsonStatus("failed: invalid from")))
+			return
+		}
+		limit, err := queryInt(req, "limit", block.HeadersPageSize)
+		if err != nil || limit < 1 || limit > block.HeadersPageSize {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(gl.JsonStatus(fmt.Sprintf("failed: limit must be between 1 and %d", block.HeadersPageSize))))
+			return
+		}
+
+		bc := bcs.GetBlockchain()
+		m, _ := json.Marshal(&block.HeadersResponse{
+			Headers: bc.Headers(from, limit),
+			Height:  bc.Height(),
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// queryInt reads an integer query parameter, returning def when it is absent.
+func queryInt(req *http.Request, name string, def int) (int, error) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
+
+func (bcs *BlockchainServer) Genesis(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		m, _ := json.Marshal(&block.GenesisResponse{
+			NetworkID: bc.Genesis().NetworkID,
+			Hash:      fmt.Sprintf("%x", bc.GenesisHash()),
+		})
+
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) Supply(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.GetBlockchain().Supply())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", bcs.GetChain)
+	mux.HandleFunc("/mine", bcs.Mine)
+	mux.HandleFunc("/mine/start", bcs.StartMine)
+	mux.HandleFunc("/mine/stats", bcs.MiningStats)
+	mux.HandleFunc("/transactions", bcs.Transactions)
+	mux.HandleFunc("/transactions/", bcs.Transaction)
+	mux.HandleFunc("/amount", bcs.Amount)
+	mux.HandleFunc("/address/", bcs.Address)
+	mux.HandleFunc("/consensus", bcs.Consensus)
+	mux.HandleFunc("/blocks", bcs.Blocks)
+	mux.HandleFunc("/blocks/", bcs.Block)
+	mux.HandleFunc("/headers", bcs.Headers)
+	mux.HandleFunc("/genesis", bcs.Genesis)
+	mux.HandleFunc("/supply", bcs.Supply)
+	return mux
+}
+
 func (bcs *BlockchainServer) Run(port uint16) {
 	bcs.port = port
 	bcs.blockchain.SetPort(port)
 	bcs.blockchain.Run()
 	log.Printf("Starting blockchain server with port %v", port)
-	http.HandleFunc("/", bcs.GetChain)
-	http.HandleFunc("/mine", bcs.Mine)
-	http.HandleFunc("/mine/start", bcs.StartMine)
-	http.HandleFunc("/transactions", bcs.Transactions)
-	http.HandleFunc("/amount", bcs.Amount)
-	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(bcs.Port())), nil))
+	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(bcs.Port())), bcs.Handler()))
 }
 
 // func (bcs *BlockchainServer) GetBlockchain() *block.Blockchain {
@@ -0,0 +1,633 @@
+package main
+
+import (
+	"blockchain/block"
+	types "blockchain/blockchaintypes"
+	"blockchain/globals"
+	"blockchain/mock_main"
+	"blockchain/wallet"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testTimestamp int64 = 1648402331651366000
+
+var testGenesis = &block.Genesis{
+	NetworkID:        "testnet",
+	Timestamp:        testTimestamp,
+	Difficulty:       block.MiningDifficulty,
+	RetargetInterval: block.DefaultRetargetInterval,
+	BlockTime:        block.MiningTimerSec,
+	Allocations:      []block.Allocation{},
+}
+
+type testNode struct {
+	server *httptest.Server
+	bcs    *BlockchainServer
+}
+
+func (n *testNode) address() string {
+	return strings.TrimPrefix(n.server.URL, "http://")
+}
+
+func (n *testNode) blockchain() *block.Blockchain {
+	return n.bcs.GetBlockchain()
+}
+
+// mine appends a block to the node's chain and announces it, even when the
+// pool is empty, which is how the tests pay rewards to the miner's address.
+func (n *testNode) mine() {
+	b, err := n.blockchain().MineBlock(context.Background())
+	if err == nil {
+		n.blockchain().AnnounceBlock(b)
+	}
+}
+
+// startNodes runs count blockchain servers on loopback ports, all sharing the
+// same genesis block and knowing about each other.
+func startNodes(t *testing.T, count int) []*testNode {
+	return startNodesWithMode(t, count, block.AccountMode)
+}
+
+func startNodesWithMode(t *testing.T, count int, mode block.LedgerMode) []*testNode {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	nodes := make([]*testNode, count)
+	for i := range nodes {
+		gl := mock_main.NewMockIGlobalLib(ctrl)
+		gl.EXPECT().EmptyByte32().AnyTimes().Return(types.Byte32{})
+		gl.EXPECT().NowUnixNano().AnyTimes().Return(testTimestamp)
+		bc, err := block.NewBlockchain(gl, block.NewMemoryStorage(), testGenesis, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bcs := NewBlockchainServer(bc)
+		server := httptest.NewServer(bcs.Handler())
+		t.Cleanup(server.Close)
+		nodes[i] = &testNode{server: server, bcs: bcs}
+	}
+	for _, n := range nodes {
+		for _, other := range nodes {
+			if n != other {
+				n.blockchain().AddNeighbor(other.address())
+			}
+		}
+	}
+	return nodes
+}
+
+// postTransaction signs a transfer with the nonce the node at url expects
+// next and submits it there.
+func postTransaction(url string, from *wallet.Wallet, to string, value types.Amount) (*http.Response, error) {
+	resp, err := http.Get(url + "/address/" + from.BlockchainAddress() + "/nonce")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var nonce block.NonceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&nonce); err != nil {
+		return nil, err
+	}
+	t := wallet.NewTransaction(from.PrivateKey(), from.PublicKey(), from.BlockchainAddress(), to, value, 0, nonce.Nonce)
+	sender, recipient := from.BlockchainAddress(), to
+	publicKey, signature := from.PublicKeyStr(), t.GenerateSignature().String()
+	m, _ := json.Marshal(&block.TransactionRequest{
+		SenderBlockchainAddress:    &sender,
+		RecipientBlockchainAddress: &recipient,
+		SenderPublicKey:            &publicKey,
+		Value:                      &value,
+		Nonce:                      &nonce.Nonce,
+		Signature:                  &signature,
+	})
+	return http.Post(url+"/transactions", "application/json", bytes.NewReader(m))
+}
+
+func TestBlockchainServer_Gossip(t *testing.T) {
+	nodes := startNodes(t, 3)
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+
+	// walletA earns the first block, which every node appends
+	nodes[0].blockchain().SetBlockchainAddress(walletA.BlockchainAddress())
+	nodes[0].mine()
+
+	Convey("an accepted transaction reaches every neighbor exactly once", t, func() {
+		resp, err := postTransaction(nodes[0].server.URL, walletA, walletB.BlockchainAddress(), types.Coin)
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+
+		for _, n := range nodes {
+			So(len(n.blockchain().TransactionPool()), ShouldEqual, 1)
+		}
+	})
+
+	Convey("a mined block is appended by every neighbor", t, func() {
+		So(nodes[1].blockchain().Mining(), ShouldBeTrue)
+
+		want := nodes[1].blockchain().LastBlock().Hash()
+		for _, n := range nodes {
+			bc := n.blockchain()
+			So(bc.LastBlock().Hash(), ShouldEqual, want)
+			So(len(bc.TransactionPool()), ShouldEqual, 0)
+		}
+	})
+
+	Convey("a node that is behind synchronizes when it hears of a new block", t, func() {
+		_, err := postTransaction(nodes[0].server.URL, walletB, walletA.BlockchainAddress(), types.Coin/2)
+		So(err, ShouldBeNil)
+		So(nodes[0].blockchain().Mining(), ShouldBeTrue)
+
+		late := startNodes(t, 1)[0]
+		late.blockchain().AddNeighbor(nodes[0].address())
+
+		m, _ := nodes[0].blockchain().LastBlock().MarshalJSON()
+		resp, err := http.Post(late.server.URL+"/blocks", "application/json", bytes.NewReader(m))
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, http.StatusAccepted)
+		So(late.blockchain().LastBlock().Hash(), ShouldEqual, nodes[0].blockchain().LastBlock().Hash())
+	})
+}
+
+func TestBlockchainServer_Transactions(t *testing.T) {
+	node := startNodes(t, 1)[0]
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+
+	Convey("a rejected transaction reports why", t, func() {
+		resp, err := postTransaction(node.server.URL, walletA, walletB.BlockchainAddress(), types.Coin)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+		var status struct {
+			Message string `json:"message"`
+		}
+		So(json.NewDecoder(resp.Body).Decode(&status), ShouldBeNil)
+		So(status.Message, ShouldEqual, "failed: "+block.ErrInsufficientBalance.Error())
+	})
+
+	Convey("a malformed public key or signature is turned away with a reason", t, func() {
+		sender, recipient := walletA.BlockchainAddress(), walletB.BlockchainAddress()
+		publicKey, signature := walletA.PublicKeyStr(), strings.Repeat("ab", 64)
+		value := types.Coin
+		for _, c := range []struct {
+			publicKey, signature, reason string
+		}{
+			{"ab", signature, "malformed sender_public_key"},
+			{publicKey[:127] + "x", signature, "malformed sender_public_key"},
+			// a well formed point that isn't on P-256
+			{publicKey[:64] + strings.Repeat("0", 63) + "1", signature, "malformed sender_public_key"},
+			{publicKey, signature[:64], "malformed signature"},
+		} {
+			m, _ := json.Marshal(&block.TransactionRequest{
+				SenderBlockchainAddress:    &sender,
+				RecipientBlockchainAddress: &recipient,
+				SenderPublicKey:            &c.publicKey,
+				Value:                      &value,
+				Signature:                  &c.signature,
+			})
+			resp, err := http.Post(node.server.URL+"/transactions", "application/json", bytes.NewReader(m))
+			So(err, ShouldBeNil)
+			var status struct {
+				Message string `json:"message"`
+			}
+			So(json.NewDecoder(resp.Body).Decode(&status), ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(status.Message, ShouldEqual, "failed: "+block.ErrInvalidRequest.Error()+": "+c.reason)
+		}
+	})
+
+	Convey("a malformed recipient address is turned away", t, func() {
+		address := walletB.BlockchainAddress()
+		for _, to := range []string{"THE BLOCKCHAIN", address[:len(address)-1]} {
+			resp, err := postTransaction(node.server.URL, walletA, to, types.Coin)
+			So(err, ShouldBeNil)
+			var status struct {
+				Message string `json:"message"`
+			}
+			So(json.NewDecoder(resp.Body).Decode(&status), ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(status.Message, ShouldStartWith, "failed: "+wallet.ErrInvalidAddress.Error())
+		}
+	})
+}
+
+func TestBlockchainServer_Genesis(t *testing.T) {
+	nodes := startNodes(t, 2)
+
+	Convey("nodes started from the same genesis file recognize each other", t, func() {
+		resp, err := http.Get(nodes[0].server.URL + "/genesis")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+
+		var gr block.GenesisResponse
+		So(json.NewDecoder(resp.Body).Decode(&gr), ShouldBeNil)
+		So(gr.NetworkID, ShouldEqual, testGenesis.NetworkID)
+		So(gr.Hash, ShouldEqual, fmt.Sprintf("%x", testGenesis.Hash()))
+		So(nodes[1].blockchain().CheckGenesis(nodes[0].address()), ShouldBeNil)
+	})
+}
+
+func TestBlockchainServer_TransactionStatus(t *testing.T) {
+	node := startNodes(t, 1)[0]
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	node.blockchain().SetBlockchainAddress(walletA.BlockchainAddress())
+	node.mine()
+
+	getStatus := func(id string) (*http.Response, *block.TransactionStatus) {
+		resp, err := http.Get(node.server.URL + "/transactions/" + id)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		status := new(block.TransactionStatus)
+		json.NewDecoder(resp.Body).Decode(status)
+		return resp, status
+	}
+	getProof := func(id string) (*http.Response, *block.TransactionProof) {
+		resp, err := http.Get(node.server.URL + "/transactions/" + id + "/proof")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		proof := new(block.TransactionProof)
+		json.NewDecoder(resp.Body).Decode(proof)
+		return resp, proof
+	}
+
+	Convey("a submitted transaction can be followed by its id", t, func() {
+		resp, err := postTransaction(node.server.URL, walletA, walletB.BlockchainAddress(), types.Coin/2)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+		var tr block.TransactionResponse
+		So(json.NewDecoder(resp.Body).Decode(&tr), ShouldBeNil)
+		So(tr.ID, ShouldHaveLength, 64)
+
+		resp, status := getStatus(tr.ID)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(status.Status, ShouldEqual, block.TransactionPending)
+		resp, _ = getProof(tr.ID)
+		So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+
+		node.mine()
+		resp, status = getStatus(tr.ID)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(status.Status, ShouldEqual, block.TransactionConfirmed)
+		So(*status.BlockHeight, ShouldEqual, 2)
+		So(status.Confirmations, ShouldEqual, 1)
+
+		resp, proof := getProof(tr.ID)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(proof.Verify(), ShouldBeTrue)
+		So(proof.BlockHeight, ShouldEqual, 2)
+		mined, _ := node.blockchain().BlockByHeight(2)
+		So(proof.BlockHash, ShouldEqual, mined.Hash)
+	})
+
+	Convey("unknown and malformed ids are told apart", t, func() {
+		resp, _ := getStatus(fmt.Sprintf("%064x", 1))
+		So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		resp, _ = getStatus("not-a-hash")
+		So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+	})
+}
+
+func TestBlockchainServer_BlockExplorer(t *testing.T) {
+	node := startNodes(t, 1)[0]
+	for i := 0; i < 3; i++ {
+		node.mine()
+	}
+	get := func(path string, v interface{}) int {
+		resp, err := http.Get(node.server.URL + path)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(v)
+		return resp.StatusCode
+	}
+
+	Convey("blocks can be paged through", t, func() {
+		var page struct {
+			Blocks []*block.BlockResponse `json:"blocks"`
+			Height int                    `json:"height"`
+		}
+		So(get("/blocks?from=1&limit=2", &page), ShouldEqual, http.StatusOK)
+		So(page.Height, ShouldEqual, 3)
+		So(len(page.Blocks), ShouldEqual, 2)
+		So(page.Blocks[0].Height, ShouldEqual, 1)
+		So(page.Blocks[1].TransactionCount, ShouldEqual, 1)
+
+		So(get("/blocks?limit=1000", &page), ShouldEqual, http.StatusBadRequest)
+		So(get("/blocks?from=x", &page), ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("a block is found by height, hash or as the latest one", t, func() {
+		var latest, byHeight, byHash block.BlockResponse
+		So(get("/blocks/latest", &latest), ShouldEqual, http.StatusOK)
+		So(latest.Height, ShouldEqual, 3)
+		So(latest.Hash, ShouldEqual, fmt.Sprintf("%x", node.blockchain().LastBlock().Hash()))
+
+		So(get("/blocks/3", &byHeight), ShouldEqual, http.StatusOK)
+		So(byHeight.Hash, ShouldEqual, latest.Hash)
+		So(get("/blocks/hash/"+latest.Hash, &byHash), ShouldEqual, http.StatusOK)
+		So(byHash.Height, ShouldEqual, 3)
+
+		var status struct{}
+		So(get("/blocks/4", &status), ShouldEqual, http.StatusNotFound)
+		So(get(fmt.Sprintf("/blocks/hash/%064x", 1), &status), ShouldEqual, http.StatusNotFound)
+		So(get("/blocks/hash/xyz", &status), ShouldEqual, http.StatusBadRequest)
+		So(get("/blocks/first", &status), ShouldEqual, http.StatusBadRequest)
+	})
+}
+
+func TestBlockchainServer_AddressTransactions(t *testing.T) {
+	node := startNodes(t, 1)[0]
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	node.blockchain().SetBlockchainAddress(walletA.BlockchainAddress())
+	node.mine()
+	resp, _ := postTransaction(node.server.URL, walletA, walletB.BlockchainAddress(), types.Coin/4)
+	resp.Body.Close()
+
+	Convey("the history of an address lists confirmed and pending transactions", t, func() {
+		resp, err := http.Get(node.server.URL + "/address/" + walletA.BlockchainAddress() + "/transactions")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+		var h block.AddressHistory
+		So(json.NewDecoder(resp.Body).Decode(&h), ShouldBeNil)
+		So(h.Balance, ShouldEqual, block.MiningReward)
+		So(len(h.Transactions), ShouldEqual, 2)
+		So(h.Transactions[0].Status, ShouldEqual, block.TransactionPending)
+		So(h.Transactions[1].Status, ShouldEqual, block.TransactionConfirmed)
+	})
+
+	Convey("the nonce of an address counts its pending transactions", t, func() {
+		resp, err := http.Get(node.server.URL + "/address/" + walletA.BlockchainAddress() + "/nonce")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+		var nr block.NonceResponse
+		So(json.NewDecoder(resp.Body).Decode(&nr), ShouldBeNil)
+		So(nr, ShouldResemble, block.NonceResponse{Address: walletA.BlockchainAddress(), Nonce: 1, ConfirmedNonce: 0})
+	})
+
+	Convey("bad cursors and paths are refused", t, func() {
+		resp, err := http.Get(node.server.URL + "/address/" + walletA.BlockchainAddress() + "/transactions?cursor=x")
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+		resp, err = http.Get(node.server.URL + "/address/" + walletA.BlockchainAddress())
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+	})
+}
+
+// lyingNode serves node's API, except for the paths lies answers itself.
+func lyingNode(t *testing.T, node *testNode, lies map[string]http.HandlerFunc) string {
+	honest := node.bcs.Handler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for prefix, lie := range lies {
+			if strings.HasPrefix(req.URL.Path, prefix) {
+				lie(w, req)
+				return
+			}
+		}
+		honest.ServeHTTP(w, req)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestLightClient(t *testing.T) {
+	nodes := startNodes(t, 2)
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	nodes[0].blockchain().SetBlockchainAddress(walletA.BlockchainAddress())
+	nodes[0].mine()
+	resp, err := postTransaction(nodes[0].server.URL, walletA, walletB.BlockchainAddress(), types.Coin/4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tr block.TransactionResponse
+	json.NewDecoder(resp.Body).Decode(&tr)
+	resp.Body.Close()
+	nodes[0].mine()
+	nodes[0].mine()
+
+	newClient := func(urls ...string) *block.LightClient {
+		lc, err := block.NewLightClient(&globals.GlobalLib{}, testGenesis, urls)
+		So(err, ShouldBeNil)
+		return lc
+	}
+	honest := []string{nodes[0].server.URL, nodes[1].server.URL}
+
+	Convey("headers served by honest nodes are verified", t, func() {
+		lc := newClient(honest...)
+		So(lc.Height(), ShouldEqual, 0)
+		for _, status := range lc.Sync() {
+			So(status.Error, ShouldBeEmpty)
+			So(status.Height, ShouldEqual, 3)
+		}
+		So(lc.Height(), ShouldEqual, 3)
+		header, ok := lc.Header(3)
+		So(ok, ShouldBeTrue)
+		So(header.Hash(), ShouldEqual, nodes[1].blockchain().LastBlock().Hash())
+	})
+
+	Convey("balances and transactions are proven against the headers", t, func() {
+		lc := newClient(honest...)
+		check, err := lc.CheckBalance(walletA.BlockchainAddress())
+		So(err, ShouldBeNil)
+		So(check.Consistent, ShouldBeTrue)
+		So(check.Balance, ShouldEqual, nodes[0].blockchain().CalculateTotalAmount(walletA.BlockchainAddress()))
+		So(check.Balance, ShouldEqual, 3*block.MiningReward-types.Coin/4)
+
+		id, _ := types.ParseByte32(tr.ID)
+		tc, err := lc.CheckTransaction(id)
+		So(err, ShouldBeNil)
+		So(tc.Confirmed, ShouldBeTrue)
+		So(*tc.BlockHeight, ShouldEqual, 2)
+		So(tc.Consistent, ShouldBeTrue)
+
+		tc, err = lc.CheckTransaction(types.Byte32{1})
+		So(err, ShouldBeNil)
+		So(tc.Confirmed, ShouldBeFalse)
+		So(tc.Consistent, ShouldBeTrue)
+	})
+
+	Convey("a node claiming a balance its history doesn't back is reported", t, func() {
+		liar := lyingNode(t, nodes[1], map[string]http.HandlerFunc{
+			"/amount": func(w http.ResponseWriter, req *http.Request) {
+				m, _ := json.Marshal(&block.AmountResponse{Amount: 1000 * types.Coin})
+				w.Write(m)
+			},
+		})
+		check, err := newClient(nodes[0].server.URL, liar).CheckBalance(walletB.BlockchainAddress())
+		So(err, ShouldBeNil)
+		So(check.Consistent, ShouldBeFalse)
+		So(check.Balance, ShouldEqual, types.Coin/4)
+		So(check.Nodes[0].Error, ShouldBeEmpty)
+		So(check.Nodes[1].Error, ShouldContainSubstring, block.ErrBalanceMismatch.Error())
+	})
+
+	Convey("a node hiding transactions is reported", t, func() {
+		liar := lyingNode(t, nodes[1], map[string]http.HandlerFunc{
+			"/amount": func(w http.ResponseWriter, req *http.Request) {
+				m, _ := json.Marshal(&block.AmountResponse{})
+				w.Write(m)
+			},
+			"/address/": func(w http.ResponseWriter, req *http.Request) {
+				m, _ := json.Marshal(&block.AddressHistory{Transactions: []*block.TransactionStatus{}})
+				w.Write(m)
+			},
+		})
+		check, err := newClient(liar, nodes[0].server.URL).CheckBalance(walletB.BlockchainAddress())
+		So(err, ShouldBeNil)
+		So(check.Consistent, ShouldBeFalse)
+		So(check.Balance, ShouldEqual, types.Coin/4)
+		So(check.Nodes[0].Error, ShouldEqual, block.ErrMissingTransactions.Error())
+	})
+
+	Convey("forged headers are rejected", t, func() {
+		liar := lyingNode(t, nodes[1], map[string]http.HandlerFunc{
+			"/headers": func(w http.ResponseWriter, req *http.Request) {
+				recorder := httptest.NewRecorder()
+				nodes[1].bcs.Handler().ServeHTTP(recorder, req)
+				var page struct {
+					Headers []map[string]interface{} `json:"headers"`
+					Height  int                      `json:"height"`
+				}
+				json.Unmarshal(recorder.Body.Bytes(), &page)
+				for _, h := range page.Headers {
+					h["timestamp"] = 0
+				}
+				m, _ := json.Marshal(page)
+				w.Write(m)
+			},
+		})
+		lc := newClient(liar)
+		statuses := lc.Sync()
+		So(statuses[0].Error, ShouldContainSubstring, block.ErrInvalidTimestamp.Error())
+		So(lc.Height(), ShouldEqual, 0)
+
+		// the honest node's headers are still taken
+		lc = newClient(liar, nodes[0].server.URL)
+		lc.Sync()
+		So(lc.Height(), ShouldEqual, 3)
+	})
+}
+
+func TestBlockchainServer_Mempool(t *testing.T) {
+	node := startNodes(t, 1)[0]
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	node.blockchain().SetBlockchainAddress(walletA.BlockchainAddress())
+	node.mine()
+	node.blockchain().SetMempoolConfig(block.MempoolConfig{MaxPerSender: 1})
+
+	Convey("pool limits are enforced and reported", t, func() {
+		resp, err := postTransaction(node.server.URL, walletA, walletB.BlockchainAddress(), types.Coin/4)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+		resp, err = postTransaction(node.server.URL, walletA, walletB.BlockchainAddress(), types.Coin/2)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+
+		resp, err = http.Get(node.server.URL + "/transactions")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		var pool struct {
+			Length int                 `json:"length"`
+			Stats  *block.MempoolStats `json:"stats"`
+		}
+		So(json.NewDecoder(resp.Body).Decode(&pool), ShouldBeNil)
+		So(pool.Length, ShouldEqual, 1)
+		So(pool.Stats.Size, ShouldEqual, 1)
+		So(pool.Stats.MaxPerSender, ShouldEqual, 1)
+		So(pool.Stats.MaxSize, ShouldEqual, block.DefaultMempoolSize)
+	})
+}
+
+func TestBlockchainServer_UTXO(t *testing.T) {
+	nodes := startNodesWithMode(t, 2, block.UTXOMode)
+	walletA := wallet.NewWallet()
+	walletB := wallet.NewWallet()
+	nodes[0].blockchain().SetBlockchainAddress(walletA.BlockchainAddress())
+	nodes[0].mine()
+	nodes[0].mine()
+
+	unspent := func(node *testNode, address string) []types.UnspentOutput {
+		resp, err := http.Get(node.server.URL + "/address/" + address + "/utxos")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		var ur block.UnspentOutputsResponse
+		So(json.NewDecoder(resp.Body).Decode(&ur), ShouldBeNil)
+		return ur.Outputs
+	}
+
+	Convey("a payment built by coin selection is accepted and relayed", t, func() {
+		available := unspent(nodes[1], walletA.BlockchainAddress())
+		So(available, ShouldHaveLength, 2)
+
+		p, err := wallet.NewPayment(walletA.PrivateKey(), walletA.PublicKey(), walletA.BlockchainAddress(),
+			walletB.BlockchainAddress(), block.MiningReward+block.MiningReward/2, 0, 0, available)
+		So(err, ShouldBeNil)
+		sender, publicKey, signature := walletA.BlockchainAddress(), walletA.PublicKeyStr(), p.GenerateSignature().String()
+		m, _ := json.Marshal(&block.TransactionRequest{
+			SenderBlockchainAddress: &sender,
+			SenderPublicKey:         &publicKey,
+			Signature:               &signature,
+			Inputs:                  p.Inputs(),
+			Outputs:                 p.Outputs(),
+		})
+		resp, err := http.Post(nodes[0].server.URL+"/transactions", "application/json", bytes.NewReader(m))
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+		So(nodes[1].blockchain().TransactionPool(), ShouldHaveLength, 1)
+		So(unspent(nodes[0], walletA.BlockchainAddress()), ShouldBeEmpty)
+
+		nodes[0].mine()
+		So(unspent(nodes[1], walletB.BlockchainAddress()), ShouldHaveLength, 1)
+		So(unspent(nodes[1], walletA.BlockchainAddress()), ShouldHaveLength, 2)
+		So(nodes[1].blockchain().CalculateTotalAmount(walletA.BlockchainAddress()), ShouldEqual, block.MiningReward+block.MiningReward/2)
+	})
+}
+
+func TestBlockchainServer_Supply(t *testing.T) {
+	nodes := startNodes(t, 2)
+	nodes[0].blockchain().SetBlockchainAddress(wallet.NewWallet().BlockchainAddress())
+	nodes[0].mine()
+	nodes[0].mine()
+
+	Convey("the supply is computed from the chain", t, func() {
+		resp, err := http.Get(nodes[1].server.URL + "/supply")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+		var sr block.SupplyResponse
+		So(json.NewDecoder(resp.Body).Decode(&sr), ShouldBeNil)
+		So(sr.Height, ShouldEqual, 2)
+		So(sr.Supply, ShouldEqual, 2*block.MiningReward)
+		So(sr.Circulating, ShouldEqual, sr.Supply)
+		So(sr.NextReward, ShouldEqual, block.MiningReward)
+	})
+}
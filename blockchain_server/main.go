@@ -7,6 +7,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"path/filepath"
 	"time"
 
 	"go.uber.org/fx"
@@ -16,16 +17,77 @@ func init() {
 	log.SetPrefix("Blockchain: ")
 }
 
-func StartServer(bcs *BlockchainServer) {
-	port := flag.Uint("port", 5000, "TCP Port Number for Blockchain Server")
-	flag.Parse()
-	fmt.Println(*port)
-	bcs.Run(uint16(*port))
+type Config struct {
+	Port          uint16
+	DataDir       string
+	GenesisFile   string
+	MiningWorkers int
+	Mempool       block.MempoolConfig
+	Ledger        block.LedgerMode
+}
+
+// NewGenesis loads the genesis file from the config, falling back to the
+// compiled-in network.
+func NewGenesis(cfg Config) (*block.Genesis, error) {
+	if cfg.GenesisFile == "" {
+		return block.DefaultGenesis(), nil
+	}
+	log.Printf("INFO: genesis file: %s", cfg.GenesisFile)
+	return block.LoadGenesis(cfg.GenesisFile)
+}
+
+func NewLedgerMode(cfg Config) block.LedgerMode {
+	log.Printf("INFO: ledger mode: %s", cfg.Ledger)
+	return cfg.Ledger
+}
+
+func NewStorage(cfg Config) (block.Storage, error) {
+	log.Printf("INFO: chain data directory: %s", cfg.DataDir)
+	return block.NewFileStorage(cfg.DataDir)
+}
+
+func StartServer(bcs *BlockchainServer, cfg Config) {
+	fmt.Println(cfg.Port)
+	bcs.GetBlockchain().SetMiningWorkers(cfg.MiningWorkers)
+	bcs.GetBlockchain().SetMempoolConfig(cfg.Mempool)
+	bcs.Run(cfg.Port)
 }
 
 func main() {
+	port := flag.Uint("port", 5000, "TCP Port Number for Blockchain Server")
+	dataDir := flag.String("data", "", "Directory for chain data (default blockchain_data/<port>)")
+	genesisFile := flag.String("genesis", "", "Genesis file of the network to join (default the built-in network)")
+	miningWorkers := flag.Int("miners", 0, "Number of proof of work goroutines (default one per CPU)")
+	mempoolSize := flag.Int("mempool-size", block.DefaultMempoolSize, "Most transactions kept pending")
+	mempoolPerSender := flag.Int("mempool-per-sender", block.DefaultMempoolSenderLimit, "Most pending transactions per sender")
+	mempoolTTL := flag.Duration("mempool-ttl", block.DefaultMempoolTTL, "How long a transaction may stay pending")
+	ledger := flag.String("ledger", block.AccountMode.String(), "Ledger mode of the network, account or utxo")
+	flag.Parse()
+	if *dataDir == "" {
+		*dataDir = filepath.Join("blockchain_data", fmt.Sprint(*port))
+	}
+	mode, err := block.ParseLedgerMode(*ledger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	app := fx.New(
+		fx.Supply(Config{
+			Port:          uint16(*port),
+			DataDir:       *dataDir,
+			GenesisFile:   *genesisFile,
+			MiningWorkers: *miningWorkers,
+			Mempool: block.MempoolConfig{
+				MaxSize:      *mempoolSize,
+				MaxPerSender: *mempoolPerSender,
+				TTL:          *mempoolTTL,
+			},
+			Ledger: mode,
+		}),
 		fx.Provide(globals.NewGlobals),
+		fx.Provide(NewLedgerMode),
+		fx.Provide(NewStorage),
+		fx.Provide(NewGenesis),
 		fx.Provide(block.NewBlockchain),
 		fx.Provide(NewBlockchainServer),
 		fx.Invoke(StartServer),
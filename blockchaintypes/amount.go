@@ -0,0 +1,125 @@
+package blockchaintypes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is a quantity of coins counted in indivisible sub-units, so values
+// add up exactly and encode the same way on every node.
+type Amount int64
+
+const (
+	AmountDecimals        = 8
+	Coin           Amount = 100_000_000
+)
+
+var (
+	ErrAmountSyntax   = errors.New("invalid amount")
+	ErrAmountOverflow = errors.New("amount out of range")
+)
+
+// ParseAmount reads a decimal string such as "12", "-0.5" or "1.00000001".
+// More than AmountDecimals fractional digits are rejected rather than rounded.
+func ParseAmount(s string) (Amount, error) {
+	digits := s
+	negative := strings.HasPrefix(digits, "-")
+	if negative {
+		digits = digits[1:]
+	}
+	whole, frac, hasPoint := strings.Cut(digits, ".")
+	if !isDigits(whole) || (hasPoint && !isDigits(frac)) || len(frac) > AmountDecimals {
+		return 0, fmt.Errorf("%w %q", ErrAmountSyntax, s)
+	}
+	frac += strings.Repeat("0", AmountDecimals-len(frac))
+
+	var units uint64
+	for _, c := range whole + frac {
+		d := uint64(c - '0')
+		if units > (math.MaxUint64-d)/10 {
+			return 0, fmt.Errorf("%w %q", ErrAmountOverflow, s)
+		}
+		units = units*10 + d
+	}
+	limit := uint64(math.MaxInt64)
+	if negative {
+		limit++
+	}
+	if units > limit {
+		return 0, fmt.Errorf("%w %q", ErrAmountOverflow, s)
+	}
+	if negative {
+		return Amount(^units + 1), nil
+	}
+	return Amount(units), nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount in coins with no trailing zeros, e.g. "1.5".
+func (a Amount) String() string {
+	sign, units := "", uint64(a)
+	if a < 0 {
+		sign, units = "-", ^uint64(a)+1
+	}
+	whole, frac := units/uint64(Coin), units%uint64(Coin)
+	if frac == 0 {
+		return sign + strconv.FormatUint(whole, 10)
+	}
+	fracStr := strings.TrimRight(fmt.Sprintf("%0*d", AmountDecimals, frac), "0")
+	return fmt.Sprintf("%s%d.%s", sign, whole, fracStr)
+}
+
+func (a Amount) Add(b Amount) (Amount, error) {
+	c := a + b
+	if (c > a) != (b > 0) {
+		return 0, ErrAmountOverflow
+	}
+	return c, nil
+}
+
+func (a Amount) Sub(b Amount) (Amount, error) {
+	c := a - b
+	if (c < a) != (b > 0) {
+		return 0, ErrAmountOverflow
+	}
+	return c, nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts the quoted form written by MarshalJSON as well as a
+// plain JSON number, which is parsed from its literal text and never rounded.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	v, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
@@ -0,0 +1,106 @@
+package blockchaintypes_test
+
+import (
+	types "blockchain/blockchaintypes"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"testing/quick"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAmount_ParseAmount(t *testing.T) {
+	Convey("decimal strings parse exactly", t, func() {
+		for s, want := range map[string]types.Amount{
+			"0":                     0,
+			"1":                     types.Coin,
+			"1.5":                   types.Coin + types.Coin/2,
+			"0.1":                   types.Coin / 10,
+			"0.00000001":            1,
+			"-2.25":                 -2*types.Coin - types.Coin/4,
+			"007.10":                7*types.Coin + types.Coin/10,
+			"92233720368.54775807":  math.MaxInt64,
+			"-92233720368.54775808": math.MinInt64,
+		} {
+			got, err := types.ParseAmount(s)
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, want)
+		}
+	})
+
+	Convey("malformed strings are rejected", t, func() {
+		for _, s := range []string{"", "-", ".", "1.", ".5", "1.000000001", "1e3", "+1", "1,5", " 1", "0x10"} {
+			_, err := types.ParseAmount(s)
+			So(errors.Is(err, types.ErrAmountSyntax), ShouldBeTrue)
+		}
+	})
+
+	Convey("out of range values are rejected", t, func() {
+		for _, s := range []string{"92233720368.54775808", "-92233720368.54775809", "99999999999999999999999"} {
+			_, err := types.ParseAmount(s)
+			So(errors.Is(err, types.ErrAmountOverflow), ShouldBeTrue)
+		}
+	})
+}
+
+func TestAmount_String(t *testing.T) {
+	Convey("amounts format without trailing zeros", t, func() {
+		So(types.Amount(0).String(), ShouldEqual, "0")
+		So((types.Coin / 2).String(), ShouldEqual, "0.5")
+		So((-types.Coin - 1).String(), ShouldEqual, "-1.00000001")
+		So(types.Amount(math.MinInt64).String(), ShouldEqual, "-92233720368.54775808")
+	})
+
+	Convey("formatting and parsing round-trip", t, func() {
+		property := func(a types.Amount) bool {
+			b, err := types.ParseAmount(a.String())
+			return err == nil && a == b
+		}
+		So(quick.Check(property, nil), ShouldBeNil)
+	})
+}
+
+func TestAmount_Arithmetic(t *testing.T) {
+	Convey("sums are exact", t, func() {
+		tenth := types.Coin / 10
+		sum := types.Amount(0)
+		for i := 0; i < 10; i++ {
+			sum, _ = sum.Add(tenth)
+		}
+		So(sum, ShouldEqual, types.Coin)
+	})
+
+	Convey("overflow is reported instead of wrapping", t, func() {
+		_, err := types.Amount(math.MaxInt64).Add(1)
+		So(errors.Is(err, types.ErrAmountOverflow), ShouldBeTrue)
+		_, err = types.Amount(math.MinInt64).Add(-1)
+		So(errors.Is(err, types.ErrAmountOverflow), ShouldBeTrue)
+		_, err = types.Amount(math.MinInt64).Sub(1)
+		So(errors.Is(err, types.ErrAmountOverflow), ShouldBeTrue)
+		_, err = types.Amount(0).Sub(math.MinInt64)
+		So(errors.Is(err, types.ErrAmountOverflow), ShouldBeTrue)
+
+		v, err := types.Amount(-1).Sub(math.MaxInt64)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, types.Amount(math.MinInt64))
+	})
+}
+
+func TestAmount_JSON(t *testing.T) {
+	Convey("amounts travel as decimal strings", t, func() {
+		m, _ := json.Marshal(types.Coin * 3 / 2)
+		So(string(m), ShouldEqual, `"1.5"`)
+
+		var a types.Amount
+		So(json.Unmarshal([]byte(`"0.3"`), &a), ShouldBeNil)
+		So(a, ShouldEqual, 3*types.Coin/10)
+
+		Convey("and plain numbers are read from their literal text", func() {
+			So(json.Unmarshal([]byte(`0.3`), &a), ShouldBeNil)
+			So(a, ShouldEqual, 3*types.Coin/10)
+			So(json.Unmarshal([]byte(`1e-1`), &a), ShouldNotBeNil)
+		})
+	})
+}
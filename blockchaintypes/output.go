@@ -0,0 +1,61 @@
+package blockchaintypes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// OutPoint names an output by the id of the transaction that created it and
+// the output's position in that transaction.
+type OutPoint struct {
+	TxID  Byte32
+	Index int
+}
+
+func (o OutPoint) String() string {
+	return fmt.Sprintf("%x:%d", o.TxID, o.Index)
+}
+
+func (o OutPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		TxID  string `json:"txid"`
+		Index int    `json:"index"`
+	}{
+		TxID:  fmt.Sprintf("%x", o.TxID),
+		Index: o.Index,
+	})
+}
+
+func (o *OutPoint) UnmarshalJSON(data []byte) error {
+	v := &struct {
+		TxID  string `json:"txid"`
+		Index *int   `json:"index"`
+	}{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	if v.Index == nil || *v.Index < 0 {
+		return errors.New("outpoint needs a non-negative index")
+	}
+	id, err := ParseByte32(v.TxID)
+	if err != nil {
+		return fmt.Errorf("outpoint txid: %w", err)
+	}
+	o.TxID = id
+	o.Index = *v.Index
+	return nil
+}
+
+// TxOutput pays Value to Address.
+type TxOutput struct {
+	Address string `json:"address"`
+	Value   Amount `json:"value"`
+}
+
+// UnspentOutput is an output nobody has spent yet, along with where it was
+// created.
+type UnspentOutput struct {
+	OutPoint OutPoint `json:"outpoint"`
+	TxOutput
+}
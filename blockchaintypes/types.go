@@ -1,4 +1,22 @@
 package blockchaintypes
 
+import (
+	"encoding/hex"
+	"fmt"
+)
+
 type EmptyByte []byte
 type Byte32 [32]byte
+
+// ParseByte32 decodes 64 hex digits, the encoding used for block and
+// transaction hashes.
+func ParseByte32(s string) (Byte32, error) {
+	var b Byte32
+	if len(s) != 2*len(b) {
+		return b, fmt.Errorf("hash must be %d hex digits, got %d", 2*len(b), len(s))
+	}
+	if _, err := hex.Decode(b[:], []byte(s)); err != nil {
+		return Byte32{}, fmt.Errorf("malformed hash: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,50 @@
+package blockchaintypes_test
+
+import (
+	types "blockchain/blockchaintypes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestByte32_ParseByte32(t *testing.T) {
+	Convey("a hex encoded hash parses back", t, func() {
+		want := types.Byte32{0xde, 0xad, 31: 0xff}
+		got, err := types.ParseByte32(fmt.Sprintf("%x", want))
+		So(err, ShouldBeNil)
+		So(got, ShouldEqual, want)
+	})
+
+	Convey("anything but 64 hex digits is rejected", t, func() {
+		for _, s := range []string{"", "abc", fmt.Sprintf("%064d", 0) + "00", "zz" + fmt.Sprintf("%062d", 0)} {
+			_, err := types.ParseByte32(s)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestOutPoint_JSON(t *testing.T) {
+	Convey("an outpoint encodes its txid in hex and parses back", t, func() {
+		want := types.OutPoint{TxID: types.Byte32{0xab, 31: 0x01}, Index: 2}
+		m, err := json.Marshal(want)
+		So(err, ShouldBeNil)
+		So(string(m), ShouldEqual, fmt.Sprintf(`{"txid":"%x","index":2}`, want.TxID))
+
+		var got types.OutPoint
+		So(json.Unmarshal(m, &got), ShouldBeNil)
+		So(got, ShouldResemble, want)
+	})
+
+	Convey("malformed outpoints are rejected", t, func() {
+		for _, s := range []string{
+			`{"txid":"ab","index":0}`,
+			fmt.Sprintf(`{"txid":"%064d"}`, 0),
+			fmt.Sprintf(`{"txid":"%064d","index":-1}`, 0),
+		} {
+			var o types.OutPoint
+			So(json.Unmarshal([]byte(s), &o), ShouldNotBeNil)
+		}
+	})
+}
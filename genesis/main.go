@@ -0,0 +1,88 @@
+package main
+
+import (
+	"blockchain/block"
+	types "blockchain/blockchaintypes"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+func init() {
+	log.SetPrefix("Genesis: ")
+}
+
+// allocations collects repeated -alloc address=amount flags.
+type allocations []block.Allocation
+
+func (a *allocations) String() string {
+	parts := make([]string, 0, len(*a))
+	for _, alloc := range *a {
+		parts = append(parts, fmt.Sprintf("%s=%s", alloc.Address, alloc.Amount))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (a *allocations) Set(s string) error {
+	address, amount, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("want address=amount, got %q", s)
+	}
+	value, err := types.ParseAmount(amount)
+	if err != nil {
+		return err
+	}
+	*a = append(*a, block.Allocation{Address: address, Amount: value})
+	return nil
+}
+
+// genesis writes a genesis file for a private network. Every node of that
+// network must be started with the same file, e.g.
+//
+//	go run ./genesis -network devnet -alloc 1ABC...=1000 -out devnet.json
+//	go run ./blockchain_server -port 5001 -genesis devnet.json
+func main() {
+	var allocs allocations
+	network := flag.String("network", "devnet", "Network id")
+	difficulty := flag.Int("difficulty", block.MiningDifficulty, "Number of leading zero hex digits the first blocks' hashes need")
+	retargetInterval := flag.Int("retarget-interval", block.DefaultRetargetInterval, "Number of blocks between difficulty adjustments")
+	blockTime := flag.Int("block-time", block.MiningTimerSec, "Desired seconds between blocks")
+	halvingInterval := flag.Int("halving-interval", block.DefaultHalvingInterval, "Number of blocks after which the mining reward halves, 0 to keep it constant")
+	maxSupply := flag.String("max-supply", block.DefaultMaxSupply.String(), "Total number of coins ever issued, allocations included, 0 for no cap")
+	coinbaseMaturity := flag.Int("coinbase-maturity", block.DefaultCoinbaseMaturity, "Number of blocks mined coins must be deep before they can be spent")
+	timestamp := flag.Int64("timestamp", 0, "Genesis timestamp in Unix nanoseconds (default now)")
+	out := flag.String("out", "genesis.json", "Where to write the genesis file")
+	flag.Var(&allocs, "alloc", "Initial balance as address=amount, may be repeated")
+	flag.Parse()
+
+	if *timestamp == 0 {
+		*timestamp = time.Now().UnixNano()
+	}
+	supply, err := types.ParseAmount(*maxSupply)
+	if err != nil {
+		log.Fatal(err)
+	}
+	g := &block.Genesis{
+		NetworkID:        *network,
+		Timestamp:        *timestamp,
+		Difficulty:       *difficulty,
+		RetargetInterval: *retargetInterval,
+		BlockTime:        *blockTime,
+		HalvingInterval:  *halvingInterval,
+		MaxSupply:        supply,
+		CoinbaseMaturity: *coinbaseMaturity,
+		Allocations:      allocs,
+	}
+	if g.Allocations == nil {
+		g.Allocations = []block.Allocation{}
+	}
+	if err := g.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := g.Save(*out); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("wrote %s\nnetwork_id %s\ngenesis_hash %x\n", *out, g.NetworkID, g.Hash())
+}
@@ -15,6 +15,8 @@ func TestGlobals_EmptyByte32(t *testing.T) {
 
 	Convey("block1 was created as expected", t, func() {
 		So(globals.EmptyByte32(), ShouldEqual, types.Byte32{})
-		So(globals.NowUnixNano(), ShouldAlmostEqual, time.Now().UnixNano())
+		before := time.Now().UnixNano()
+		now := globals.NowUnixNano()
+		So(now, ShouldBeBetweenOrEqual, before, time.Now().UnixNano())
 	})
 }
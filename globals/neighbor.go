@@ -13,7 +13,7 @@ import (
 var IpPattern = regexp.MustCompile(`((25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?\.){3})(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)`)
 
 func IsFoundHost(host string, port uint16) bool {
-	target := fmt.Sprintf("%s:%d", host, port)
+	target := net.JoinHostPort(host, strconv.Itoa(int(port)))
 	_, err := net.DialTimeout("tcp", target, 1*time.Second)
 	if err != nil {
 		log.Printf("ERROR: an error occurred:\n %s %v\n", target, err)
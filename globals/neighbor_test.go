@@ -0,0 +1,45 @@
+package globals_test
+
+import (
+	"blockchain/globals"
+	"net"
+	"strconv"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIsFoundHost(t *testing.T) {
+	listen := func(network, address string) (string, uint16) {
+		l, err := net.Listen(network, address)
+		if err != nil {
+			return "", 0
+		}
+		t.Cleanup(func() { l.Close() })
+		host, port, _ := net.SplitHostPort(l.Addr().String())
+		p, _ := strconv.Atoi(port)
+		return host, uint16(p)
+	}
+
+	Convey("a listening IPv4 host is found", t, func() {
+		host, port := listen("tcp4", "127.0.0.1:0")
+		So(globals.IsFoundHost(host, port), ShouldBeTrue)
+	})
+
+	Convey("a listening IPv6 host is found", t, func() {
+		host, port := listen("tcp6", "[::1]:0")
+		if host == "" {
+			SkipSo("no IPv6 loopback")
+			return
+		}
+		So(globals.IsFoundHost(host, port), ShouldBeTrue)
+	})
+
+	Convey("a closed port is not", t, func() {
+		l, err := net.Listen("tcp4", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		port := uint16(l.Addr().(*net.TCPAddr).Port)
+		l.Close()
+		So(globals.IsFoundHost("127.0.0.1", port), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"blockchain/wallet"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func init() {
+	log.SetPrefix("HD Wallet: ")
+}
+
+// prompt asks for a line on stdin, so that secrets stay out of the shell
+// history and the process list.
+func prompt(in *bufio.Reader, question string) string {
+	fmt.Fprint(os.Stderr, question)
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatal(err)
+	}
+	return strings.TrimSpace(line)
+}
+
+// hdwallet creates or recovers an HD wallet on this machine and stores its
+// keys in the keystore the wallet server reads. The mnemonic never leaves
+// the terminal: a new one is shown once to be written down, a recovered one
+// is only used to derive the keys, e.g.
+//
+//	go run ./hdwallet -keystore keystore
+//	go run ./hdwallet -keystore keystore -recover -gateway http://127.0.0.1:5000
+//
+// The keys are unlocked on the wallet server with their passphrase.
+func main() {
+	keystoreDir := flag.String("keystore", "keystore", "Directory holding the encrypted wallet keys")
+	lightKDF := flag.Bool("light-kdf", false, "Encrypt new keys with cheap scrypt parameters, for throwaway networks only")
+	recover := flag.Bool("recover", false, "Recover a wallet from its mnemonic instead of creating one")
+	gateway := flag.String("gateway", "http://127.0.0.1:5000", "Blockchain node to look up used addresses at when recovering")
+	flag.Parse()
+
+	n, p := wallet.StandardScryptN, wallet.StandardScryptP
+	if *lightKDF {
+		n, p = wallet.LightScryptN, wallet.LightScryptP
+	}
+	ks, err := wallet.NewKeystore(*keystoreDir, n, p)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	var mnemonic string
+	var used wallet.UsedFunc
+	if *recover {
+		mnemonic = prompt(in, "Mnemonic: ")
+		used = wallet.NodeHistory(*gateway)
+	} else if mnemonic, err = wallet.NewMnemonic(wallet.DefaultEntropyBits); err != nil {
+		log.Fatal(err)
+	}
+	passphrase := prompt(in, "Passphrase: ")
+	if passphrase == "" {
+		log.Fatal("passphrase must not be empty")
+	}
+
+	infos, err := ks.StoreMnemonic(mnemonic, passphrase, used)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !*recover {
+		fmt.Printf("mnemonic %s\nwrite it down, it is the only backup of the wallet and is not stored\n", mnemonic)
+	}
+	for _, info := range infos {
+		fmt.Printf("stored %s %s\n", info.ID, info.BlockchainAddress)
+	}
+}
@@ -5,8 +5,10 @@
 package mock_main
 
 import (
-	"blockchain/blockchaintypes"
-
+	blockchaintypes "blockchain/blockchaintypes"
+	globals "blockchain/globals"
+	ecdsa "crypto/ecdsa"
+	http "net/http"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -35,6 +37,20 @@ func (m *MockIGlobalLib) EXPECT() *MockIGlobalLibMockRecorder {
 	return m.recorder
 }
 
+// DecodeJSONBody mocks base method.
+func (m *MockIGlobalLib) DecodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecodeJSONBody", w, r, dst)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DecodeJSONBody indicates an expected call of DecodeJSONBody.
+func (mr *MockIGlobalLibMockRecorder) DecodeJSONBody(w, r, dst interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecodeJSONBody", reflect.TypeOf((*MockIGlobalLib)(nil).DecodeJSONBody), w, r, dst)
+}
+
 // EmptyByte32 mocks base method.
 func (m *MockIGlobalLib) EmptyByte32() blockchaintypes.Byte32 {
 	m.ctrl.T.Helper()
@@ -49,6 +65,49 @@ func (mr *MockIGlobalLibMockRecorder) EmptyByte32() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EmptyByte32", reflect.TypeOf((*MockIGlobalLib)(nil).EmptyByte32))
 }
 
+// GetApplicationJson mocks base method.
+func (m *MockIGlobalLib) GetApplicationJson() (string, string) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApplicationJson")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	return ret0, ret1
+}
+
+// GetApplicationJson indicates an expected call of GetApplicationJson.
+func (mr *MockIGlobalLibMockRecorder) GetApplicationJson() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApplicationJson", reflect.TypeOf((*MockIGlobalLib)(nil).GetApplicationJson))
+}
+
+// IsHttpOk mocks base method.
+func (m *MockIGlobalLib) IsHttpOk(err error, w http.ResponseWriter) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsHttpOk", err, w)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsHttpOk indicates an expected call of IsHttpOk.
+func (mr *MockIGlobalLibMockRecorder) IsHttpOk(err, w interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsHttpOk", reflect.TypeOf((*MockIGlobalLib)(nil).IsHttpOk), err, w)
+}
+
+// JsonStatus mocks base method.
+func (m *MockIGlobalLib) JsonStatus(message string) []byte {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JsonStatus", message)
+	ret0, _ := ret[0].([]byte)
+	return ret0
+}
+
+// JsonStatus indicates an expected call of JsonStatus.
+func (mr *MockIGlobalLibMockRecorder) JsonStatus(message interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JsonStatus", reflect.TypeOf((*MockIGlobalLib)(nil).JsonStatus), message)
+}
+
 // NowUnixNano mocks base method.
 func (m *MockIGlobalLib) NowUnixNano() int64 {
 	m.ctrl.T.Helper()
@@ -62,3 +121,45 @@ func (mr *MockIGlobalLibMockRecorder) NowUnixNano() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NowUnixNano", reflect.TypeOf((*MockIGlobalLib)(nil).NowUnixNano))
 }
+
+// PrivateKeyFromString mocks base method.
+func (m *MockIGlobalLib) PrivateKeyFromString(s string, publicKey *ecdsa.PublicKey) *ecdsa.PrivateKey {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrivateKeyFromString", s, publicKey)
+	ret0, _ := ret[0].(*ecdsa.PrivateKey)
+	return ret0
+}
+
+// PrivateKeyFromString indicates an expected call of PrivateKeyFromString.
+func (mr *MockIGlobalLibMockRecorder) PrivateKeyFromString(s, publicKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrivateKeyFromString", reflect.TypeOf((*MockIGlobalLib)(nil).PrivateKeyFromString), s, publicKey)
+}
+
+// PublicKeyFromString mocks base method.
+func (m *MockIGlobalLib) PublicKeyFromString(s string) *ecdsa.PublicKey {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublicKeyFromString", s)
+	ret0, _ := ret[0].(*ecdsa.PublicKey)
+	return ret0
+}
+
+// PublicKeyFromString indicates an expected call of PublicKeyFromString.
+func (mr *MockIGlobalLibMockRecorder) PublicKeyFromString(s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublicKeyFromString", reflect.TypeOf((*MockIGlobalLib)(nil).PublicKeyFromString), s)
+}
+
+// SignatureFromString mocks base method.
+func (m *MockIGlobalLib) SignatureFromString(s string) *globals.Signature {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SignatureFromString", s)
+	ret0, _ := ret[0].(*globals.Signature)
+	return ret0
+}
+
+// SignatureFromString indicates an expected call of SignatureFromString.
+func (mr *MockIGlobalLibMockRecorder) SignatureFromString(s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignatureFromString", reflect.TypeOf((*MockIGlobalLib)(nil).SignatureFromString), s)
+}
@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	types "blockchain/blockchaintypes"
+	"crypto/ecdsa"
+	"errors"
+	"sort"
+)
+
+var ErrInsufficientFunds = errors.New("unspent outputs don't cover the amount")
+
+// SelectCoins picks outputs from available worth at least target and
+// returns them with the change left over. The smallest output covering
+// target on its own is preferred, an exact match when there is one;
+// otherwise the largest outputs are taken until they add up, which keeps the
+// number of inputs low.
+func SelectCoins(available []types.UnspentOutput, target types.Amount) ([]types.UnspentOutput, types.Amount, error) {
+	if target <= 0 {
+		return nil, 0, errors.New("target must be positive")
+	}
+	sorted := make([]types.UnspentOutput, len(available))
+	copy(sorted, available)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Value < sorted[j].Value })
+
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i].Value >= target })
+	if i < len(sorted) {
+		return sorted[i : i+1], sorted[i].Value - target, nil
+	}
+
+	var total types.Amount
+	for j := len(sorted) - 1; j >= 0; j-- {
+		var err error
+		if total, err = total.Add(sorted[j].Value); err != nil {
+			return nil, 0, err
+		}
+		if total >= target {
+			return sorted[j:], total - target, nil
+		}
+	}
+	return nil, 0, ErrInsufficientFunds
+}
+
+// NewPayment builds a UTXO transaction from sender to recipient. It spends
+// outputs chosen from available, which must belong to sender, to cover value
+// and fee, and returns the change to sender.
+func NewPayment(
+	privateKey *ecdsa.PrivateKey,
+	publicKey *ecdsa.PublicKey,
+	sender string,
+	recipient string,
+	value types.Amount,
+	fee types.Amount,
+	nonce uint64,
+	available []types.UnspentOutput) (*Transaction, error) {
+	target, err := value.Add(fee)
+	if err != nil {
+		return nil, err
+	}
+	selected, change, err := SelectCoins(available, target)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]types.OutPoint, len(selected))
+	for i, u := range selected {
+		inputs[i] = u.OutPoint
+	}
+	outputs := []types.TxOutput{{Address: recipient, Value: value}}
+	if change > 0 {
+		outputs = append(outputs, types.TxOutput{Address: sender, Value: change})
+	}
+	return NewUTXOTransaction(privateKey, publicKey, sender, inputs, outputs, fee, nonce), nil
+}
@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	types "blockchain/blockchaintypes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// UsedFunc reports whether an address has ever been used on the chain.
+type UsedFunc func(blockchainAddress string) (bool, error)
+
+// DiscoveredKey is a child key found by Discover.
+type DiscoveredKey struct {
+	Index uint32
+	Key   *ExtendedKey
+	Used  bool
+}
+
+// Discover derives the children of chain, e.g. the key at
+// DefaultDerivationPath, in order and asks used about each address until
+// gapLimit unused ones follow the last used one. It returns the used
+// children followed by the first unused one after them, which is where the
+// wallet receives next.
+func Discover(chain *ExtendedKey, gapLimit int, used UsedFunc) ([]*DiscoveredKey, error) {
+	if gapLimit < 1 {
+		return nil, errors.New("gap limit must be positive")
+	}
+	found := []*DiscoveredKey{}
+	var next *DiscoveredKey
+	for index, gap := uint32(0), 0; gap < gapLimit; index++ {
+		if index >= HardenedOffset {
+			return nil, ErrDerivationDepth
+		}
+		key, err := chain.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		isUsed, err := used(key.Wallet().BlockchainAddress())
+		if err != nil {
+			return nil, fmt.Errorf("checking child %d: %w", index, err)
+		}
+		if isUsed {
+			found = append(found, &DiscoveredKey{Index: index, Key: key, Used: true})
+			next, gap = nil, 0
+			continue
+		}
+		if next == nil {
+			next = &DiscoveredKey{Index: index, Key: key}
+		}
+		gap++
+	}
+	return append(found, next), nil
+}
+
+// StoreMnemonic stores the keys of the HD wallet mnemonic backs up in ks,
+// encrypted with passphrase: the children of DefaultDerivationPath that
+// Discover finds used, plus the next one to receive at. Keys ks holds already
+// are skipped. A nil used stands for a fresh wallet, which only stores the
+// first key. The mnemonic itself is not kept.
+func (ks *Keystore) StoreMnemonic(mnemonic string, passphrase string, used UsedFunc) ([]*KeyInfo, error) {
+	if _, err := EntropyFromMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	if used == nil {
+		used = func(string) (bool, error) { return false, nil }
+	}
+	master, err := NewMasterKey(MnemonicToSeed(mnemonic, ""))
+	if err != nil {
+		return nil, err
+	}
+	chain, err := master.Derive(DefaultDerivationPath)
+	if err != nil {
+		return nil, err
+	}
+	found, err := Discover(chain, DefaultGapLimit, used)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := ks.List()
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]bool, len(existing))
+	for _, info := range existing {
+		stored[info.BlockchainAddress] = true
+	}
+
+	infos := []*KeyInfo{}
+	for _, d := range found {
+		w := d.Key.Wallet()
+		if stored[w.BlockchainAddress()] {
+			continue
+		}
+		info, err := ks.Store(w, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// NodeHistory checks addresses against the history a blockchain node keeps,
+// counting an address as used once it has a transaction or a balance.
+func NodeHistory(gateway string) UsedFunc {
+	return func(blockchainAddress string) (bool, error) {
+		resp, err := http.Get(fmt.Sprintf("%s/address/%s/transactions", gateway, url.PathEscape(blockchainAddress)))
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return false, fmt.Errorf("node answered %d", resp.StatusCode)
+		}
+		var history struct {
+			Balance      types.Amount      `json:"balance"`
+			Transactions []json.RawMessage `json:"transactions"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+			return false, err
+		}
+		return len(history.Transactions) > 0 || history.Balance != 0, nil
+	}
+}
@@ -0,0 +1,211 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	// HardenedOffset is added to a child index to derive a hardened child,
+	// written with a trailing ' in paths.
+	HardenedOffset uint32 = 0x80000000
+
+	// DefaultDerivationPath is the receiving chain of the first account,
+	// after BIP44; its children are the wallet's addresses.
+	DefaultDerivationPath = "m/44'/0'/0'/0"
+	// DefaultGapLimit is how many unused addresses in a row end discovery.
+	DefaultGapLimit = 20
+
+	// masterKeySalt is what SLIP-10 keys the master key HMAC with for
+	// NIST P-256.
+	masterKeySalt = "Nist256p1 seed"
+)
+
+var (
+	ErrSeedLength      = errors.New("seed must be 16 to 64 bytes")
+	ErrInvalidPath     = errors.New("derivation path is invalid")
+	ErrDerivationDepth = errors.New("derivation path is too deep")
+)
+
+// ExtendedKey is a node of an HD wallet: a private key along with the chain
+// code its children are derived with. Keys are derived as SLIP-10 specifies
+// BIP32 for the P-256 curve wallets use.
+type ExtendedKey struct {
+	privateKey        []byte
+	chainCode         []byte
+	depth             uint8
+	index             uint32
+	parentFingerprint [4]byte
+}
+
+// NewMasterKey makes the root of an HD wallet from a seed, usually one
+// MnemonicToSeed returned.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, ErrSeedLength
+	}
+	data := seed
+	for {
+		mac := hmac.New(sha512.New, []byte(masterKeySalt))
+		mac.Write(data)
+		i := mac.Sum(nil)
+		if validScalar(i[:32]) {
+			return &ExtendedKey{privateKey: i[:32], chainCode: i[32:]}, nil
+		}
+		// SLIP-10 retries with the whole output for the rare invalid key
+		data = i
+	}
+}
+
+// Child derives the child at index, a hardened one if index is at least
+// HardenedOffset.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if k.depth == 255 {
+		return nil, ErrDerivationDepth
+	}
+	data := make([]byte, 0, 37)
+	if index >= HardenedOffset {
+		data = append(append(data, 0), k.privateKey...)
+	} else {
+		data = append(data, k.PublicKeyBytes()...)
+	}
+	data = append(data, ser32(index)...)
+
+	n := elliptic.P256().Params().N
+	for {
+		mac := hmac.New(sha512.New, k.chainCode)
+		mac.Write(data)
+		i := mac.Sum(nil)
+		if validScalar(i[:32]) {
+			child := new(big.Int).SetBytes(i[:32])
+			child.Add(child, new(big.Int).SetBytes(k.privateKey))
+			child.Mod(child, n)
+			if child.Sign() != 0 {
+				return &ExtendedKey{
+					privateKey:        child.FillBytes(make([]byte, 32)),
+					chainCode:         i[32:],
+					depth:             k.depth + 1,
+					index:             index,
+					parentFingerprint: k.Fingerprint(),
+				}, nil
+			}
+		}
+		// SLIP-10 retries with the right half for the rare invalid key
+		data = append(append([]byte{1}, i[32:]...), ser32(index)...)
+	}
+}
+
+// Derive follows path from k. A path starting with m, e.g.
+// "m/44'/0'/0'/0/7", can only be followed from the master key; other keys
+// take paths relative to them, e.g. "0/7".
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	if (path == "m" || strings.HasPrefix(path, "m/")) && k.depth != 0 {
+		return nil, fmt.Errorf("%w: %q starts at the master key, not at a depth %d key", ErrInvalidPath, path, k.depth)
+	}
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	key := k
+	for _, index := range indexes {
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// ParsePath reads a derivation path into child indexes. Hardened steps end
+// in ' or h.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] == "m" {
+		parts = parts[1:]
+	}
+	indexes := make([]uint32, 0, len(parts))
+	for _, p := range parts {
+		offset := uint32(0)
+		if strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h") || strings.HasSuffix(p, "H") {
+			offset = HardenedOffset
+			p = p[:len(p)-1]
+		}
+		index, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || uint32(index) >= HardenedOffset {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+		}
+		indexes = append(indexes, uint32(index)+offset)
+	}
+	return indexes, nil
+}
+
+// PrivateKey is the key's ECDSA private key.
+func (k *ExtendedKey) PrivateKey() *ecdsa.PrivateKey {
+	// derivation only ever produces valid scalars
+	privateKey, _ := privateKeyFromBytes(k.privateKey)
+	return privateKey
+}
+
+// PublicKeyBytes is the compressed public key.
+func (k *ExtendedKey) PublicKeyBytes() []byte {
+	p := k.PrivateKey()
+	return elliptic.MarshalCompressed(p.Curve, p.X, p.Y)
+}
+
+// ChainCode is what the key's children are derived with.
+func (k *ExtendedKey) ChainCode() []byte {
+	return append([]byte{}, k.chainCode...)
+}
+
+// Depth is the number of derivation steps from the master key.
+func (k *ExtendedKey) Depth() uint8 {
+	return k.depth
+}
+
+// Index is the child index the key was derived at.
+func (k *ExtendedKey) Index() uint32 {
+	return k.index
+}
+
+// Fingerprint identifies the key: the first four bytes of the RIPEMD-160
+// hash of the SHA-256 hash of its compressed public key.
+func (k *ExtendedKey) Fingerprint() [4]byte {
+	h := sha256.Sum256(k.PublicKeyBytes())
+	r := ripemd160.New()
+	r.Write(h[:])
+	var fp [4]byte
+	copy(fp[:], r.Sum(nil))
+	return fp
+}
+
+// ParentFingerprint is the fingerprint of the key k was derived from, zero
+// for the master key.
+func (k *ExtendedKey) ParentFingerprint() [4]byte {
+	return k.parentFingerprint
+}
+
+// Wallet is the wallet of the key, with its blockchain address.
+func (k *ExtendedKey) Wallet() *Wallet {
+	return newWalletFromKey(k.PrivateKey())
+}
+
+func ser32(i uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
+
+func validScalar(b []byte) bool {
+	d := new(big.Int).SetBytes(b)
+	return d.Sign() != 0 && d.Cmp(elliptic.P256().Params().N) < 0
+}
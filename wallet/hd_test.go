@@ -0,0 +1,269 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMnemonic(t *testing.T) {
+	// vectors from the BIP39 reference implementation, passphrase TREZOR
+	vectors := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"80808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+			"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+	}
+
+	Convey("mnemonics match the reference vectors", t, func() {
+		So(words, ShouldHaveLength, 2048)
+		for _, v := range vectors {
+			entropy, _ := hex.DecodeString(v.entropy)
+			mnemonic, err := MnemonicFromEntropy(entropy)
+			So(err, ShouldBeNil)
+			So(mnemonic, ShouldEqual, v.mnemonic)
+
+			decoded, err := EntropyFromMnemonic(mnemonic)
+			So(err, ShouldBeNil)
+			So(hex.EncodeToString(decoded), ShouldEqual, v.entropy)
+			So(hex.EncodeToString(MnemonicToSeed(mnemonic, "TREZOR")), ShouldEqual, v.seed)
+		}
+	})
+
+	Convey("new mnemonics decode back", t, func() {
+		for _, bits := range []int{128, 160, 192, 224, 256} {
+			mnemonic, err := NewMnemonic(bits)
+			So(err, ShouldBeNil)
+			So(strings.Fields(mnemonic), ShouldHaveLength, bits*3/32)
+			So(IsValidMnemonic(mnemonic), ShouldBeTrue)
+		}
+		_, err := NewMnemonic(100)
+		So(errors.Is(err, ErrEntropyLength), ShouldBeTrue)
+	})
+
+	Convey("mistyped mnemonics are rejected", t, func() {
+		_, err := EntropyFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon")
+		So(errors.Is(err, ErrMnemonicChecksum), ShouldBeTrue)
+		_, err = EntropyFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abut")
+		So(errors.Is(err, ErrInvalidMnemonic), ShouldBeTrue)
+		_, err = EntropyFromMnemonic("abandon about")
+		So(errors.Is(err, ErrInvalidMnemonic), ShouldBeTrue)
+	})
+}
+
+func TestExtendedKey(t *testing.T) {
+	// SLIP-10 test vector 1 for nist256p1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	vectors := []struct {
+		path        string
+		fingerprint string
+		chainCode   string
+		privateKey  string
+		publicKey   string
+	}{
+		{
+			"m", "00000000",
+			"beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea",
+			"612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2",
+			"0266874dc6ade47b3ecd096745ca09bcd29638dd52c2c12117b11ed3e458cfa9e8",
+		},
+		{
+			"m/0'", "be6105b5",
+			"3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11",
+			"6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c",
+			"0384610f5ecffe8fda089363a41f56a5c7ffc1d81b59a612d0d649b2d22355590c",
+		},
+		{
+			"m/0'/1", "9b02312f",
+			"4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c",
+			"284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129",
+			"03526c63f8d0b4bbbf9c80df553fe66742df4676b241dabefdef67733e070f6844",
+		},
+		{
+			"m/0'/1/2'", "b98005c1",
+			"98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318",
+			"694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7",
+			"0359cf160040778a4b14c5f4d7b76e327ccc8c4a6086dd9451b7482b5a4972dda0",
+		},
+		{
+			"m/0'/1/2'/2", "0e9f3274",
+			"ba96f776a5c3907d7fd48bde5620ee374d4acfd540378476019eab70790c63a0",
+			"5996c37fd3dd2679039b23ed6f70b506c6b56b3cb5e424681fb0fa64caf82aaa",
+			"029f871f4cb9e1c97f9f4de9ccd0d4a2f2a171110c61178f84430062230833ff20",
+		},
+		{
+			"m/0'/1/2'/2/1000000000", "8b2b5c4b",
+			"b9b7b82d326bb9cb5b5b121066feea4eb93d5241103c9e7a18aad40f1dde8059",
+			"21c4f269ef0a5fd1badf47eeacebeeaa3de22eb8e5b0adcd0f27dd99d34d0119",
+			"02216cd26d31147f72427a453c443ed2cde8a1e53c9cc44e5ddf739725413fe3f4",
+		},
+	}
+
+	Convey("derived keys match the reference vectors", t, func() {
+		master, err := NewMasterKey(seed)
+		So(err, ShouldBeNil)
+		for _, v := range vectors {
+			key, err := master.Derive(v.path)
+			So(err, ShouldBeNil)
+			fp := key.ParentFingerprint()
+			So(hex.EncodeToString(fp[:]), ShouldEqual, v.fingerprint)
+			So(hex.EncodeToString(key.ChainCode()), ShouldEqual, v.chainCode)
+			So(fmt.Sprintf("%064x", key.PrivateKey().D), ShouldEqual, v.privateKey)
+			So(hex.EncodeToString(key.PublicKeyBytes()), ShouldEqual, v.publicKey)
+		}
+	})
+
+	Convey("paths are parsed step by step", t, func() {
+		indexes, err := ParsePath("m/44'/0h/7")
+		So(err, ShouldBeNil)
+		So(indexes, ShouldResemble, []uint32{44 + HardenedOffset, HardenedOffset, 7})
+		for _, bad := range []string{"", "m/", "m/x", "m/-1", "m/2147483648", "m/1''"} {
+			_, err := ParsePath(bad)
+			So(errors.Is(err, ErrInvalidPath), ShouldBeTrue)
+		}
+	})
+
+	Convey("only the master key follows paths starting with m", t, func() {
+		master, _ := NewMasterKey(seed)
+		chain, err := master.Derive("m/0'/1")
+		So(err, ShouldBeNil)
+		_, err = chain.Derive("m/2'")
+		So(errors.Is(err, ErrInvalidPath), ShouldBeTrue)
+		_, err = chain.Derive("m")
+		So(errors.Is(err, ErrInvalidPath), ShouldBeTrue)
+
+		relative, err := chain.Derive("2'/2")
+		So(err, ShouldBeNil)
+		absolute, _ := master.Derive("m/0'/1/2'/2")
+		So(relative.PrivateKey().D, ShouldResemble, absolute.PrivateKey().D)
+	})
+
+	Convey("the same mnemonic always gives the same addresses", t, func() {
+		mnemonic := "legal winner thank year wave sausage worth useful legal winner thank yellow"
+		a, err := NewMasterKey(MnemonicToSeed(mnemonic, ""))
+		So(err, ShouldBeNil)
+		b, err := NewMasterKey(MnemonicToSeed(mnemonic, ""))
+		So(err, ShouldBeNil)
+		keyA, err := a.Derive(DefaultDerivationPath + "/3")
+		So(err, ShouldBeNil)
+		keyB, err := b.Derive(DefaultDerivationPath + "/3")
+		So(err, ShouldBeNil)
+		So(keyA.Wallet().BlockchainAddress(), ShouldEqual, keyB.Wallet().BlockchainAddress())
+
+		other, err := NewMasterKey(MnemonicToSeed(mnemonic, "other"))
+		So(err, ShouldBeNil)
+		keyC, err := other.Derive(DefaultDerivationPath + "/3")
+		So(err, ShouldBeNil)
+		So(keyC.Wallet().BlockchainAddress(), ShouldNotEqual, keyA.Wallet().BlockchainAddress())
+	})
+}
+
+func TestDiscover(t *testing.T) {
+	master, _ := NewMasterKey(MnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", ""))
+	chain, _ := master.Derive(DefaultDerivationPath)
+	address := func(index uint32) string {
+		key, _ := chain.Child(index)
+		return key.Wallet().BlockchainAddress()
+	}
+
+	Convey("discovery stops after gap limit unused addresses", t, func() {
+		used := map[string]bool{address(0): true, address(1): true, address(5): true, address(11): true}
+		asked := 0
+		found, err := Discover(chain, 5, func(a string) (bool, error) {
+			asked++
+			return used[a], nil
+		})
+		So(err, ShouldBeNil)
+		indexes := []uint32{}
+		for _, d := range found {
+			indexes = append(indexes, d.Index)
+		}
+		// 11 lies beyond the gap of five after 5
+		So(indexes, ShouldResemble, []uint32{0, 1, 5, 6})
+		So(found[3].Used, ShouldBeFalse)
+		So(asked, ShouldEqual, 11)
+	})
+
+	Convey("a fresh wallet receives at its first address", t, func() {
+		found, err := Discover(chain, DefaultGapLimit, func(string) (bool, error) { return false, nil })
+		So(err, ShouldBeNil)
+		So(found, ShouldHaveLength, 1)
+		So(found[0].Key.Wallet().BlockchainAddress(), ShouldEqual, address(0))
+	})
+
+	Convey("addresses are checked against a node's history", t, func() {
+		node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/address/" + address(0) + "/transactions":
+				fmt.Fprint(w, `{"address":"a","balance":"0","transactions":[{"id":"00"}]}`)
+			case "/address/" + address(1) + "/transactions":
+				fmt.Fprint(w, `{"address":"a","balance":"1.5","transactions":[]}`)
+			default:
+				fmt.Fprint(w, `{"address":"a","balance":"0","transactions":[]}`)
+			}
+		}))
+		defer node.Close()
+
+		found, err := Discover(chain, 3, NodeHistory(node.URL))
+		So(err, ShouldBeNil)
+		So(found, ShouldHaveLength, 3)
+		So(found[2].Index, ShouldEqual, 2)
+
+		node.Close()
+		_, err = Discover(chain, 3, NodeHistory(node.URL))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("a mnemonic's used keys are stored in the keystore once", t, func() {
+		mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+		used := map[string]bool{address(0): true, address(2): true}
+		ks := newTestKeystore(t)
+		infos, err := ks.StoreMnemonic(mnemonic, "secret", func(a string) (bool, error) { return used[a], nil })
+		So(err, ShouldBeNil)
+		addresses := []string{}
+		for _, info := range infos {
+			addresses = append(addresses, info.BlockchainAddress)
+		}
+		So(addresses, ShouldResemble, []string{address(0), address(2), address(3)})
+
+		again, err := ks.StoreMnemonic(mnemonic, "secret", nil)
+		So(err, ShouldBeNil)
+		So(again, ShouldBeEmpty)
+		listed, _ := ks.List()
+		So(listed, ShouldHaveLength, 3)
+
+		_, err = ks.StoreMnemonic("abandon about", "secret", nil)
+		So(errors.Is(err, ErrInvalidMnemonic), ShouldBeTrue)
+	})
+}
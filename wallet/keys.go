@@ -0,0 +1,148 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+const (
+	// AddressVersion is the version byte of blockchain addresses.
+	AddressVersion byte = 0x00
+	// PrivateKeyVersion is the version byte of private keys in WIF-like
+	// form.
+	PrivateKeyVersion byte = 0x80
+
+	pemTypeEC    = "EC PRIVATE KEY"
+	pemTypePKCS8 = "PRIVATE KEY"
+)
+
+var (
+	ErrKeyEncoding    = errors.New("private key encoding is invalid")
+	ErrInvalidAddress = errors.New("blockchain address is malformed")
+)
+
+// FromPrivateKey builds the wallet of an existing P-256 private key. The
+// public key is computed from the private one, whatever privateKey holds.
+func FromPrivateKey(privateKey *ecdsa.PrivateKey) (*Wallet, error) {
+	if privateKey == nil || privateKey.D == nil || privateKey.Curve != elliptic.P256() {
+		return nil, ErrInvalidPrivateKey
+	}
+	k, err := privateKeyFromBytes(privateKey.D.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return newWalletFromKey(k), nil
+}
+
+// ImportHex reads a private key in the hex form ExportHex and PrivateKeyStr
+// write.
+func ImportHex(s string) (*Wallet, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) == 0 || len(b) > 32 {
+		return nil, ErrKeyEncoding
+	}
+	k, err := privateKeyFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return newWalletFromKey(k), nil
+}
+
+// ExportHex writes the private key as 64 hex digits.
+func (w *Wallet) ExportHex() string {
+	return hex.EncodeToString(w.privateKey.D.FillBytes(make([]byte, 32)))
+}
+
+// ImportPEM reads a private key from a PEM block holding either a SEC 1 EC
+// private key or a PKCS #8 one.
+func ImportPEM(data []byte) (*Wallet, error) {
+	b, _ := pem.Decode(data)
+	if b == nil {
+		return nil, ErrKeyEncoding
+	}
+	var key interface{}
+	var err error
+	switch b.Type {
+	case pemTypeEC:
+		key, err = x509.ParseECPrivateKey(b.Bytes)
+	case pemTypePKCS8:
+		key, err = x509.ParsePKCS8PrivateKey(b.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrKeyEncoding, b.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyEncoding, err)
+	}
+	privateKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: not an ECDSA key", ErrKeyEncoding)
+	}
+	return FromPrivateKey(privateKey)
+}
+
+// ExportPEM writes the private key as a SEC 1 "EC PRIVATE KEY" PEM block.
+func (w *Wallet) ExportPEM() ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(w.privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemTypeEC, Bytes: der}), nil
+}
+
+// ImportWIF reads a private key in the base58check form ExportWIF writes.
+func ImportWIF(s string) (*Wallet, error) {
+	b, version, err := base58.CheckDecode(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyEncoding, err)
+	}
+	if version != PrivateKeyVersion || len(b) != 32 {
+		return nil, ErrKeyEncoding
+	}
+	k, err := privateKeyFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return newWalletFromKey(k), nil
+}
+
+// ExportWIF writes the private key like Bitcoin's wallet import format: the
+// version byte PrivateKeyVersion and the 32 key bytes, followed by a 4 byte
+// checksum and encoded in base58.
+func (w *Wallet) ExportWIF() string {
+	return base58.CheckEncode(w.privateKey.D.FillBytes(make([]byte, 32)), PrivateKeyVersion)
+}
+
+// ImportPrivateKey reads a private key in any of the forms the Export
+// methods write. 64 hex digits are always read as hex, so a key out of range
+// is reported as such rather than as a bad WIF string.
+func ImportPrivateKey(s string) (*Wallet, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "-----BEGIN"):
+		return ImportPEM([]byte(s))
+	case len(s) == 64 && isHex(s):
+		return ImportHex(s)
+	}
+	return ImportWIF(s)
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// IsValidAddress reports whether address is a base58 encoded blockchain
+// address as NewWallet makes them: the version byte AddressVersion and a
+// 20 byte public key hash, followed by a matching 4 byte checksum.
+func IsValidAddress(address string) bool {
+	b, version, err := base58.CheckDecode(address)
+	return err == nil && version == AddressVersion && len(b) == 20
+}
@@ -0,0 +1,370 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// KeyFileVersion is the version of the key file format Keystore writes.
+	// Version 1 derives a 32 byte key from the passphrase with scrypt (the
+	// parameters and salt are in kdfparams) and seals the 32 byte private key
+	// with AES-256-GCM under it, using the nonce in cipherparams and the
+	// address as additional data. The layout resembles Ethereum's keystore,
+	// but the files can't be read as one.
+	KeyFileVersion = 1
+
+	// StandardScryptN and StandardScryptP make deriving a key take about a
+	// second, which is what passphrases on disk need.
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+	// LightScryptN and LightScryptP are much cheaper, for tests and
+	// throwaway networks.
+	LightScryptN = 1 << 12
+	LightScryptP = 6
+
+	scryptR     = 8
+	scryptDKLen = 32
+	keyFileExt  = ".json"
+)
+
+var (
+	ErrKeyNotFound     = errors.New("no such key in the keystore")
+	ErrWrongPassphrase = errors.New("passphrase doesn't decrypt the key")
+	ErrLocked          = errors.New("key is locked")
+	ErrKeyFileVersion  = errors.New("unsupported key file version")
+	ErrInvalidKeyFile  = errors.New("key file is invalid")
+	ErrAddressMismatch = errors.New("decrypted key doesn't match the key file's address")
+)
+
+// KeyFile is a private key as Keystore keeps it on disk, encrypted with a
+// key derived from a passphrase.
+type KeyFile struct {
+	Version int        `json:"version"`
+	ID      string     `json:"id"`
+	Address string     `json:"address"`
+	Crypto  CryptoJSON `json:"crypto"`
+}
+
+// CryptoJSON holds the encrypted private key and what it takes to decrypt
+// it. The address is authenticated along with the ciphertext, so a key file
+// can't be relabeled.
+type CryptoJSON struct {
+	Cipher       string          `json:"cipher"`
+	CipherText   string          `json:"ciphertext"`
+	CipherParams CipherParamsGCM `json:"cipherparams"`
+	KDF          string          `json:"kdf"`
+	KDFParams    ScryptParams    `json:"kdfparams"`
+}
+
+type CipherParamsGCM struct {
+	Nonce string `json:"nonce"`
+}
+
+type ScryptParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+// KeyInfo describes a key in the keystore without giving anything secret
+// away.
+type KeyInfo struct {
+	ID                string `json:"id"`
+	BlockchainAddress string `json:"blockchain_address"`
+	PublicKey         string `json:"public_key,omitempty"`
+	Unlocked          bool   `json:"unlocked"`
+}
+
+// Keystore keeps private keys in a directory, one encrypted key file per
+// key, and holds the ones that have been unlocked in memory until they are
+// locked again.
+type Keystore struct {
+	dir      string
+	scryptN  int
+	scryptP  int
+	mux      sync.Mutex
+	unlocked map[string]*Wallet
+}
+
+// NewKeystore opens the keystore in dir, creating the directory if needed.
+// New keys are encrypted with the given scrypt cost parameters.
+func NewKeystore(dir string, scryptN int, scryptP int) (*Keystore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Keystore{
+		dir:      dir,
+		scryptN:  scryptN,
+		scryptP:  scryptP,
+		unlocked: make(map[string]*Wallet),
+	}, nil
+}
+
+// New creates a key, stores it encrypted with passphrase and leaves it
+// unlocked.
+func (ks *Keystore) New(passphrase string) (*KeyInfo, error) {
+	return ks.Store(NewWallet(), passphrase)
+}
+
+// Store saves the key of w encrypted with passphrase under a new id and
+// leaves it unlocked.
+func (ks *Keystore) Store(w *Wallet, passphrase string) (*KeyInfo, error) {
+	id, err := newKeyID()
+	if err != nil {
+		return nil, err
+	}
+	kf, err := encryptKey(id, w, passphrase, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return nil, err
+	}
+	m, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(ks.path(id), m, 0o600); err != nil {
+		return nil, err
+	}
+
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	ks.unlocked[id] = w
+	return ks.info(kf), nil
+}
+
+// Load reads the key file of id without decrypting it.
+func (ks *Keystore) Load(id string) (*KeyFile, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, ErrKeyNotFound
+	}
+	m, err := os.ReadFile(ks.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	kf := new(KeyFile)
+	if err := json.Unmarshal(m, kf); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKeyFile, err)
+	}
+	if kf.Version != KeyFileVersion {
+		return nil, fmt.Errorf("%w %d", ErrKeyFileVersion, kf.Version)
+	}
+	if kf.ID != id {
+		return nil, fmt.Errorf("%w: file of %s holds id %s", ErrInvalidKeyFile, id, kf.ID)
+	}
+	return kf, nil
+}
+
+// List describes every key in the keystore, ordered by address. Files that
+// aren't key files are skipped.
+func (ks *Keystore) List() ([]*KeyInfo, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+
+	infos := []*KeyInfo{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, keyFileExt) {
+			continue
+		}
+		kf, err := ks.Load(strings.TrimSuffix(name, keyFileExt))
+		if err != nil {
+			continue
+		}
+		infos = append(infos, ks.info(kf))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].BlockchainAddress < infos[j].BlockchainAddress })
+	return infos, nil
+}
+
+// Unlock decrypts the key of id with passphrase and keeps it in memory until
+// Lock is called.
+func (ks *Keystore) Unlock(id string, passphrase string) (*KeyInfo, error) {
+	kf, err := ks.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	w, err := decryptKey(kf, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	ks.unlocked[id] = w
+	return ks.info(kf), nil
+}
+
+// Lock forgets the decrypted key of id.
+func (ks *Keystore) Lock(id string) error {
+	if _, err := ks.Load(id); err != nil {
+		return err
+	}
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	delete(ks.unlocked, id)
+	return nil
+}
+
+// Wallet returns the wallet of id if it is unlocked.
+func (ks *Keystore) Wallet(id string) (*Wallet, error) {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	w, ok := ks.unlocked[id]
+	if !ok {
+		if _, err := ks.Load(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrLocked
+	}
+	return w, nil
+}
+
+// WalletByAddress returns the unlocked wallet of blockchainAddress.
+func (ks *Keystore) WalletByAddress(blockchainAddress string) (*Wallet, error) {
+	infos, err := ks.List()
+	if err != nil {
+		return nil, err
+	}
+	locked := false
+	for _, info := range infos {
+		if info.BlockchainAddress != blockchainAddress {
+			continue
+		}
+		if w, err := ks.Wallet(info.ID); err == nil {
+			return w, nil
+		}
+		locked = true
+	}
+	if locked {
+		return nil, ErrLocked
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (ks *Keystore) path(id string) string {
+	return filepath.Join(ks.dir, id+keyFileExt)
+}
+
+// info describes kf. Callers must hold ks.mux.
+func (ks *Keystore) info(kf *KeyFile) *KeyInfo {
+	info := &KeyInfo{ID: kf.ID, BlockchainAddress: kf.Address}
+	if w, ok := ks.unlocked[kf.ID]; ok {
+		info.PublicKey = w.PublicKeyStr()
+		info.Unlocked = true
+	}
+	return info
+}
+
+func encryptKey(id string, w *Wallet, passphrase string, scryptN int, scryptP int) (*KeyFile, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	params := ScryptParams{N: scryptN, R: scryptR, P: scryptP, DKLen: scryptDKLen, Salt: hex.EncodeToString(salt)}
+	gcm, err := newGCM(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	plaintext := w.privateKey.D.FillBytes(make([]byte, 32))
+	ciphertext := gcm.Seal(nil, nonce, plaintext, []byte(w.BlockchainAddress()))
+	return &KeyFile{
+		Version: KeyFileVersion,
+		ID:      id,
+		Address: w.BlockchainAddress(),
+		Crypto: CryptoJSON{
+			Cipher:       "aes-256-gcm",
+			CipherText:   hex.EncodeToString(ciphertext),
+			CipherParams: CipherParamsGCM{Nonce: hex.EncodeToString(nonce)},
+			KDF:          "scrypt",
+			KDFParams:    params,
+		},
+	}, nil
+}
+
+func decryptKey(kf *KeyFile, passphrase string) (*Wallet, error) {
+	if kf.Crypto.Cipher != "aes-256-gcm" || kf.Crypto.KDF != "scrypt" {
+		return nil, fmt.Errorf("%w: cipher %q with kdf %q", ErrInvalidKeyFile, kf.Crypto.Cipher, kf.Crypto.KDF)
+	}
+	gcm, err := newGCM(passphrase, kf.Crypto.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(kf.Crypto.CipherParams.Nonce)
+	if err != nil || len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: bad nonce", ErrInvalidKeyFile)
+	}
+	ciphertext, err := hex.DecodeString(kf.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad ciphertext", ErrInvalidKeyFile)
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(kf.Address))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	privateKey, err := privateKeyFromBytes(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKeyFile, err)
+	}
+	w := newWalletFromKey(privateKey)
+	if w.BlockchainAddress() != kf.Address {
+		return nil, ErrAddressMismatch
+	}
+	return w, nil
+}
+
+// newGCM derives the encryption key from passphrase.
+func newGCM(passphrase string, params ScryptParams) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad salt", ErrInvalidKeyFile)
+	}
+	if params.DKLen != scryptDKLen {
+		return nil, fmt.Errorf("%w: dklen %d", ErrInvalidKeyFile, params.DKLen)
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKeyFile, err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newKeyID returns a random (version 4) UUID.
+func newKeyID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newTestKeystore(t *testing.T) *Keystore {
+	ks, err := NewKeystore(t.TempDir(), LightScryptN, LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func TestKeystore(t *testing.T) {
+	Convey("a stored key unlocks to the same wallet", t, func() {
+		ks := newTestKeystore(t)
+		w := NewWallet()
+		info, err := ks.Store(w, "secret")
+		So(err, ShouldBeNil)
+		So(info.BlockchainAddress, ShouldEqual, w.BlockchainAddress())
+		So(info.Unlocked, ShouldBeTrue)
+
+		// a second keystore on the same directory starts out locked
+		reopened, err := NewKeystore(ks.dir, LightScryptN, LightScryptP)
+		So(err, ShouldBeNil)
+		_, err = reopened.Wallet(info.ID)
+		So(errors.Is(err, ErrLocked), ShouldBeTrue)
+
+		info, err = reopened.Unlock(info.ID, "secret")
+		So(err, ShouldBeNil)
+		So(info.PublicKey, ShouldEqual, w.PublicKeyStr())
+		unlocked, err := reopened.Wallet(info.ID)
+		So(err, ShouldBeNil)
+		So(unlocked.PrivateKeyStr(), ShouldEqual, w.PrivateKeyStr())
+		So(unlocked.BlockchainAddress(), ShouldEqual, w.BlockchainAddress())
+	})
+
+	Convey("the key file holds no plaintext key", t, func() {
+		ks := newTestKeystore(t)
+		w := NewWallet()
+		info, err := ks.Store(w, "secret")
+		So(err, ShouldBeNil)
+		m, err := os.ReadFile(ks.path(info.ID))
+		So(err, ShouldBeNil)
+		So(string(m), ShouldNotContainSubstring, w.PrivateKeyStr())
+		So(string(m), ShouldContainSubstring, `"version": 1`)
+
+		stat, err := os.Stat(ks.path(info.ID))
+		So(err, ShouldBeNil)
+		So(stat.Mode().Perm(), ShouldEqual, os.FileMode(0o600))
+
+		// an Ethereum v3 key file is a different format
+		So(os.WriteFile(ks.path(info.ID), []byte(strings.Replace(string(m), `"version": 1`, `"version": 3`, 1)), 0o600), ShouldBeNil)
+		_, err = ks.Load(info.ID)
+		So(errors.Is(err, ErrKeyFileVersion), ShouldBeTrue)
+	})
+
+	Convey("a wrong passphrase doesn't unlock the key", t, func() {
+		ks := newTestKeystore(t)
+		info, err := ks.New("secret")
+		So(err, ShouldBeNil)
+		So(ks.Lock(info.ID), ShouldBeNil)
+		_, err = ks.Unlock(info.ID, "guess")
+		So(errors.Is(err, ErrWrongPassphrase), ShouldBeTrue)
+		_, err = ks.Wallet(info.ID)
+		So(errors.Is(err, ErrLocked), ShouldBeTrue)
+	})
+
+	Convey("a relabeled key file is rejected", t, func() {
+		ks := newTestKeystore(t)
+		info, err := ks.New("secret")
+		So(err, ShouldBeNil)
+		m, err := os.ReadFile(ks.path(info.ID))
+		So(err, ShouldBeNil)
+		relabeled := strings.Replace(string(m), info.BlockchainAddress, NewWallet().BlockchainAddress(), 1)
+		So(os.WriteFile(ks.path(info.ID), []byte(relabeled), 0o600), ShouldBeNil)
+		_, err = ks.Unlock(info.ID, "secret")
+		So(errors.Is(err, ErrWrongPassphrase), ShouldBeTrue)
+	})
+
+	Convey("list describes every key file and skips the rest", t, func() {
+		ks := newTestKeystore(t)
+		a, err := ks.New("a")
+		So(err, ShouldBeNil)
+		b, err := ks.New("b")
+		So(err, ShouldBeNil)
+		So(ks.Lock(b.ID), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(ks.dir, "notes.txt"), []byte("hi"), 0o600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(ks.dir, "broken.json"), []byte("{"), 0o600), ShouldBeNil)
+
+		infos, err := ks.List()
+		So(err, ShouldBeNil)
+		So(infos, ShouldHaveLength, 2)
+		unlocked := map[string]bool{}
+		for _, info := range infos {
+			unlocked[info.ID] = info.Unlocked
+		}
+		So(unlocked, ShouldResemble, map[string]bool{a.ID: true, b.ID: false})
+	})
+
+	Convey("wallets can be looked up by address once unlocked", t, func() {
+		ks := newTestKeystore(t)
+		w := NewWallet()
+		info, err := ks.Store(w, "secret")
+		So(err, ShouldBeNil)
+		found, err := ks.WalletByAddress(w.BlockchainAddress())
+		So(err, ShouldBeNil)
+		So(found.PrivateKeyStr(), ShouldEqual, w.PrivateKeyStr())
+
+		So(ks.Lock(info.ID), ShouldBeNil)
+		_, err = ks.WalletByAddress(w.BlockchainAddress())
+		So(errors.Is(err, ErrLocked), ShouldBeTrue)
+		_, err = ks.WalletByAddress(NewWallet().BlockchainAddress())
+		So(errors.Is(err, ErrKeyNotFound), ShouldBeTrue)
+	})
+
+	Convey("unknown ids are reported as such", t, func() {
+		ks := newTestKeystore(t)
+		_, err := ks.Unlock("00000000-0000-4000-8000-000000000000", "secret")
+		So(errors.Is(err, ErrKeyNotFound), ShouldBeTrue)
+		_, err = ks.Load("../etc/passwd")
+		So(errors.Is(err, ErrKeyNotFound), ShouldBeTrue)
+		So(errors.Is(ks.Lock("nope"), ErrKeyNotFound), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// wordlistEnglish is the BIP39 English wordlist, one word per line.
+//
+//go:embed wordlist_english.txt
+var wordlistEnglish string
+
+var (
+	words     = strings.Fields(wordlistEnglish)
+	wordIndex = make(map[string]int, len(words))
+)
+
+func init() {
+	for i, w := range words {
+		wordIndex[w] = i
+	}
+}
+
+const (
+	// DefaultEntropyBits gives a 12 word mnemonic.
+	DefaultEntropyBits = 128
+
+	seedIterations = 2048
+	seedLength     = 64
+)
+
+var (
+	ErrEntropyLength    = errors.New("entropy must be 128 to 256 bits, a multiple of 32")
+	ErrInvalidMnemonic  = errors.New("mnemonic has the wrong number of words or a word that isn't in the wordlist")
+	ErrMnemonicChecksum = errors.New("mnemonic checksum doesn't match")
+)
+
+// NewMnemonic generates a BIP39 mnemonic for bits of fresh entropy.
+func NewMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrEntropyLength
+	}
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return MnemonicFromEntropy(entropy)
+}
+
+// MnemonicFromEntropy encodes entropy as words: the entropy followed by the
+// first len(entropy)/4 bits of its SHA-256 hash, eleven bits per word.
+func MnemonicFromEntropy(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrEntropyLength
+	}
+	checksum := sha256.Sum256(entropy)
+	data := append(append([]byte{}, entropy...), checksum[0])
+	n := (bits + bits/32) / 11
+	mnemonic := make([]string, n)
+	for i := range mnemonic {
+		mnemonic[i] = words[readBits(data, i*11, 11)]
+	}
+	return strings.Join(mnemonic, " "), nil
+}
+
+// EntropyFromMnemonic decodes a mnemonic and checks its checksum.
+func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
+	fields := strings.Fields(mnemonic)
+	if len(fields) < 12 || len(fields) > 24 || len(fields)%3 != 0 {
+		return nil, ErrInvalidMnemonic
+	}
+	total := len(fields) * 11
+	checksumBits := total / 33
+	data := make([]byte, (total+7)/8)
+	for i, f := range fields {
+		index, ok := wordIndex[f]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMnemonic, f)
+		}
+		writeBits(data, i*11, 11, index)
+	}
+	entropy := data[:(total-checksumBits)/8]
+	checksum := sha256.Sum256(entropy)
+	if readBits(data, total-checksumBits, checksumBits) != readBits(checksum[:], 0, checksumBits) {
+		return nil, ErrMnemonicChecksum
+	}
+	return entropy, nil
+}
+
+// IsValidMnemonic reports whether mnemonic is made of wordlist words and
+// carries a matching checksum.
+func IsValidMnemonic(mnemonic string) bool {
+	_, err := EntropyFromMnemonic(mnemonic)
+	return err == nil
+}
+
+// MnemonicToSeed turns a mnemonic and an optional passphrase into the seed
+// an HD wallet's master key is made from. Any passphrase gives a valid, but
+// different, seed. Words are expected in lower case ASCII as the wordlist has
+// them; passphrases aren't Unicode-normalized.
+func MnemonicToSeed(mnemonic string, passphrase string) []byte {
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), seedIterations, seedLength, sha512.New)
+}
+
+// readBits reads n bits of data starting at bit offset, most significant
+// first.
+func readBits(data []byte, offset int, n int) int {
+	v := 0
+	for i := offset; i < offset+n; i++ {
+		v = v<<1 | int(data[i/8]>>(7-i%8)&1)
+	}
+	return v
+}
+
+// writeBits writes the low n bits of v into data at bit offset.
+func writeBits(data []byte, offset int, n int, v int) {
+	for i := 0; i < n; i++ {
+		if v>>(n-1-i)&1 == 1 {
+			pos := offset + i
+			data[pos/8] |= 1 << (7 - pos%8)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	types "blockchain/blockchaintypes"
 	"blockchain/globals"
 	"crypto/ecdsa"
 	"crypto/rand"
@@ -13,7 +14,11 @@ type Transaction struct {
 	senderPublicKey            *ecdsa.PublicKey
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
-	value                      float32
+	value                      types.Amount
+	fee                        types.Amount
+	nonce                      uint64
+	inputs                     []types.OutPoint
+	outputs                    []types.TxOutput
 }
 
 func NewTransaction(
@@ -21,16 +26,53 @@ func NewTransaction(
 	publicKey *ecdsa.PublicKey,
 	sender string,
 	recipient string,
-	value float32) *Transaction {
+	value types.Amount,
+	fee types.Amount,
+	nonce uint64) *Transaction {
 	return &Transaction{
 		senderPrivateKey:           privateKey,
 		senderPublicKey:            publicKey,
 		senderBlockchainAddress:    sender,
 		recipientBlockchainAddress: recipient,
 		value:                      value,
+		fee:                        fee,
+		nonce:                      nonce,
 	}
 }
 
+// NewUTXOTransaction spends the sender's outputs named by inputs and creates
+// outputs in their place. Like block.NewSignedUTXOTransaction, it takes the
+// recipient from the first output and the value as the sum of them all.
+func NewUTXOTransaction(
+	privateKey *ecdsa.PrivateKey,
+	publicKey *ecdsa.PublicKey,
+	sender string,
+	inputs []types.OutPoint,
+	outputs []types.TxOutput,
+	fee types.Amount,
+	nonce uint64) *Transaction {
+	var recipient string
+	var value types.Amount
+	for i, o := range outputs {
+		if i == 0 {
+			recipient = o.Address
+		}
+		value += o.Value
+	}
+	t := NewTransaction(privateKey, publicKey, sender, recipient, value, fee, nonce)
+	t.inputs = inputs
+	t.outputs = outputs
+	return t
+}
+
+func (t *Transaction) Inputs() []types.OutPoint {
+	return t.inputs
+}
+
+func (t *Transaction) Outputs() []types.TxOutput {
+	return t.outputs
+}
+
 func (t *Transaction) GenerateSignature() *globals.Signature {
 	m, _ := json.Marshal(t)
 	h := sha256.Sum256([]byte(m))
@@ -43,12 +85,20 @@ func (t *Transaction) GenerateSignature() *globals.Signature {
 
 func (t *Transaction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Sender    string  `json:"sender_blockchain_address"`
-		Recipient string  `json:"recipient_blockchain_address"`
-		Value     float32 `json:"value"`
+		Sender    string           `json:"sender_blockchain_address"`
+		Recipient string           `json:"recipient_blockchain_address"`
+		Value     types.Amount     `json:"value"`
+		Fee       types.Amount     `json:"fee"`
+		Nonce     uint64           `json:"nonce"`
+		Inputs    []types.OutPoint `json:"inputs,omitempty"`
+		Outputs   []types.TxOutput `json:"outputs,omitempty"`
 	}{
 		t.senderBlockchainAddress,
 		t.recipientBlockchainAddress,
 		t.value,
+		t.fee,
+		t.nonce,
+		t.inputs,
+		t.outputs,
 	})
 }
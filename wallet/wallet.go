@@ -6,7 +6,9 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 
 	"github.com/btcsuite/btcutil/base58"
 	"golang.org/x/crypto/ripemd160"
@@ -20,15 +22,42 @@ type Wallet struct {
 
 func NewWallet() *Wallet {
 	// 1. Creating ECDSA private key (32 bytes) public key (64 bytes)
-	w := new(Wallet)
 	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return newWalletFromKey(privateKey)
+}
+
+var ErrInvalidPrivateKey = errors.New("private key is out of range")
+
+// privateKeyFromBytes reads a P-256 private key from its big-endian scalar
+// and computes the public key that goes with it.
+func privateKeyFromBytes(b []byte) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(b)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, ErrInvalidPrivateKey
+	}
+	privateKey := &ecdsa.PrivateKey{D: d}
+	privateKey.Curve = curve
+	privateKey.X, privateKey.Y = curve.ScalarBaseMult(d.Bytes())
+	return privateKey, nil
+}
+
+// newWalletFromKey builds the wallet of an existing private key.
+func newWalletFromKey(privateKey *ecdsa.PrivateKey) *Wallet {
+	w := new(Wallet)
 	w.privateKey = privateKey
 	w.publicKey = &w.privateKey.PublicKey
+	w.blockchainAddress = AddressFromPublicKey(w.publicKey)
+	return w
+}
 
+// AddressFromPublicKey derives the blockchain address of a public key, the
+// one its wallet has. Nodes check a transaction's sender address against it.
+func AddressFromPublicKey(publicKey *ecdsa.PublicKey) string {
 	// 2. Perform SHA-256 hashing on the public key (32 bytes).
 	h2 := sha256.New()
-	h2.Write(w.publicKey.X.Bytes())
-	h2.Write(w.publicKey.Y.Bytes())
+	h2.Write(publicKey.X.Bytes())
+	h2.Write(publicKey.Y.Bytes())
 	digest2 := h2.Sum(nil)
 
 	// 3. Perform RIPEMD-160 hashing on the result of SHA-256 (20 bytes).
@@ -38,7 +67,7 @@ func NewWallet() *Wallet {
 
 	// 4. Add version byte in front of RIPEMD-160 hash (0x00 for Main Network).
 	vd4 := make([]byte, 21)
-	vd4[0] = 0x00
+	vd4[0] = AddressVersion
 	copy(vd4[1:], digest3[:])
 
 	// 5. Perform SHA-256 hash on the extended RIPEMD-160 result.
@@ -60,9 +89,7 @@ func NewWallet() *Wallet {
 	copy(dc8[21:], chsum[:])
 
 	// 9. Convert the result from a byte string into base58.
-	address := base58.Encode(dc8)
-	w.blockchainAddress = address
-	return w
+	return base58.Encode(dc8)
 }
 
 func (w *Wallet) PrivateKey() *ecdsa.PrivateKey {
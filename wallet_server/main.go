@@ -1,9 +1,12 @@
 package main
 
 import (
+	"blockchain/block"
 	"blockchain/globals"
+	"blockchain/wallet"
 	"flag"
 	"log"
+	"strings"
 )
 
 func init() {
@@ -13,11 +16,50 @@ func init() {
 func main() {
 	port := flag.Uint("port", 8080, "TCP Port for Wallet Server")
 	gateway := flag.String("gateway", "http://127.0.0.1:5000", "Blockchain Gateway")
+	light := flag.Bool("light", false, "Verify balances and transactions against block headers instead of trusting the gateway")
+	nodes := flag.String("nodes", "", "Comma separated blockchain nodes to cross-check in light mode (default the gateway)")
+	genesisFile := flag.String("genesis", "", "Genesis file of the network in light mode (default the built-in genesis)")
+	ledger := flag.String("ledger", block.AccountMode.String(), "Ledger mode of the network, account or utxo")
+	keystoreDir := flag.String("keystore", "keystore", "Directory holding the encrypted wallet keys")
+	lightKDF := flag.Bool("light-kdf", false, "Encrypt new keys with cheap scrypt parameters, for throwaway networks only")
+	acceptPrivateKeys := flag.Bool("accept-private-keys", false, "Still sign transactions with a sender_private_key sent by the client")
 	flag.Parse()
+	mode, err := block.ParseLedgerMode(*ledger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	scryptN, scryptP := wallet.StandardScryptN, wallet.StandardScryptP
+	if *lightKDF {
+		scryptN, scryptP = wallet.LightScryptN, wallet.LightScryptP
+	}
+	keystore, err := wallet.NewKeystore(*keystoreDir, scryptN, scryptP)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	log.Println("INFO: Blockchain gateway configured as:", *gateway)
 	lib := &globals.GlobalLib{}
 
-	ws := NewWalletServer(*port, *gateway, lib)
+	var lightClient *block.LightClient
+	if *light {
+		genesis := block.DefaultGenesis()
+		if *genesisFile != "" {
+			if genesis, err = block.LoadGenesis(*genesisFile); err != nil {
+				log.Fatal(err)
+			}
+		}
+		urls := []string{*gateway}
+		if *nodes != "" {
+			urls = strings.Split(*nodes, ",")
+		}
+		if lightClient, err = block.NewLightClient(lib, genesis, urls); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *acceptPrivateKeys {
+		log.Println("WARN: accepting private keys over the API")
+	}
+	ws := NewWalletServer(*port, *gateway, lib, lightClient, mode, keystore, *acceptPrivateKeys)
 	ws.Run()
 }
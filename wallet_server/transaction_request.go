@@ -1,25 +1,37 @@
 package main
 
+// TransactionRequest asks the wallet server to sign and send a transaction.
+// The sender is an unlocked keystore wallet named by WalletID or by
+// SenderBlockchainAddress. SenderPrivateKey, in any form wallet.ImportPrivateKey
+// reads, is only used when the server still accepts private keys; the
+// sender's address and public key then follow from it.
 type TransactionRequest struct {
+	WalletID                   *string `json:"wallet_id"`
 	RecipientBlockchainAddress *string `json:"recipient_blockchain_address"`
 	SenderPrivateKey           *string `json:"sender_private_key"`
 	SenderBlockchainAddress    *string `json:"sender_blockchain_address"`
 	SenderPublicKey            *string `json:"sender_public_key"`
 	SenderSendAmount           *string `json:"sender_send_amount"`
+	SenderFee                  *string `json:"sender_fee"`
 }
 
 func (tr *TransactionRequest) Validate() bool {
-	if tr.SenderPrivateKey == nil ||
-		tr.SenderBlockchainAddress == nil ||
-		tr.RecipientBlockchainAddress == nil ||
-		tr.SenderPublicKey == nil ||
+	if tr.RecipientBlockchainAddress == nil ||
 		tr.SenderSendAmount == nil ||
-		*tr.SenderPrivateKey == "" ||
-		*tr.SenderBlockchainAddress == "" ||
 		*tr.RecipientBlockchainAddress == "" ||
-		*tr.SenderPublicKey == "" ||
 		*tr.SenderSendAmount == "" {
 		return false
 	}
-	return true
+	if tr.SenderPrivateKey != nil {
+		return *tr.SenderPrivateKey != ""
+	}
+	return (tr.WalletID != nil && *tr.WalletID != "") ||
+		(tr.SenderBlockchainAddress != nil && *tr.SenderBlockchainAddress != "")
+}
+
+// KeystoreRequest names a keystore wallet and the passphrase to create or
+// unlock it with.
+type KeystoreRequest struct {
+	ID         *string `json:"id"`
+	Passphrase *string `json:"passphrase"`
 }
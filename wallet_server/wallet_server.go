@@ -2,17 +2,21 @@ package main
 
 import (
 	"blockchain/block"
+	types "blockchain/blockchaintypes"
 	"blockchain/globals"
 	"blockchain/wallet"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"path"
 	"strconv"
 	"text/template"
+	"time"
 )
 
 const tempDir = "templates"
@@ -21,13 +25,31 @@ type WalletServer struct {
 	port    uint
 	gateway string
 	lib     globals.IGlobalLib
+	// lightClient, when set, checks balances and transactions against
+	// verified headers and several nodes instead of trusting the gateway
+	lightClient *block.LightClient
+	// mode is the ledger mode of the network; in UTXOMode transactions spend
+	// outputs picked by coin selection
+	mode block.LedgerMode
+	// keystore holds the wallets' private keys, encrypted on disk; clients
+	// refer to them by id or address
+	keystore *wallet.Keystore
+	// acceptPrivateKeys lets clients still send sender_private_key along
+	// with a transaction
+	acceptPrivateKeys bool
 }
 
-func NewWalletServer(port uint, gateway string, lib globals.IGlobalLib) *WalletServer {
+var ErrPrivateKeyField = errors.New("sender_private_key is not accepted: sign with an unlocked wallet by wallet_id or sender_blockchain_address, or POST a signed transaction to /transaction/signed")
+
+func NewWalletServer(port uint, gateway string, lib globals.IGlobalLib, lightClient *block.LightClient, mode block.LedgerMode, keystore *wallet.Keystore, acceptPrivateKeys bool) *WalletServer {
 	return &WalletServer{
-		port:    port,
-		gateway: gateway,
-		lib:     lib,
+		port:              port,
+		gateway:           gateway,
+		lib:               lib,
+		lightClient:       lightClient,
+		mode:              mode,
+		keystore:          keystore,
+		acceptPrivateKeys: acceptPrivateKeys,
 	}
 }
 
@@ -52,22 +74,119 @@ func (ws *WalletServer) Index(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// Wallet lists the keystore's wallets on GET. POST creates a wallet
+// encrypted with the passphrase of the request and leaves it unlocked; the
+// answer names it by id and never holds the private key.
 func (ws *WalletServer) Wallet(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		infos, err := ws.keystore.List()
+		if err != nil {
+			ws.keystoreError(w, err)
+			return
+		}
+		m, _ := json.Marshal(infos)
+		io.WriteString(w, string(m[:]))
 	case http.MethodPost:
 		w.Header().Add("Content-Type", "application/json")
-		myWallet := wallet.NewWallet()
-		m, _ := myWallet.MarshalJSON()
-		_, err := w.Write(m)
+		var kr KeystoreRequest
+		err := ws.lib.DecodeJSONBody(w, req, &kr)
+		if !ws.lib.IsHttpOk(err, w) {
+			return
+		}
+		if kr.Passphrase == nil || *kr.Passphrase == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus("failed: missing passphrase")))
+			return
+		}
+		info, err := ws.keystore.New(*kr.Passphrase)
+		if err != nil {
+			ws.keystoreError(w, err)
+			return
+		}
+		log.Printf("INFO: created wallet %s address=%s", info.ID, info.BlockchainAddress)
+		m, _ := json.Marshal(info)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// WalletUnlock decrypts the keystore wallet id with passphrase so that
+// transactions can be signed with it.
+func (ws *WalletServer) WalletUnlock(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var kr KeystoreRequest
+		err := ws.lib.DecodeJSONBody(w, req, &kr)
+		if !ws.lib.IsHttpOk(err, w) {
+			return
+		}
+		if kr.ID == nil || kr.Passphrase == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus("failed: missing id or passphrase")))
+			return
+		}
+		info, err := ws.keystore.Unlock(*kr.ID, *kr.Passphrase)
 		if err != nil {
-			log.Println(err)
+			ws.keystoreError(w, err)
+			return
 		}
+		m, _ := json.Marshal(info)
+		io.WriteString(w, string(m[:]))
 	default:
 		w.WriteHeader(http.StatusBadRequest)
 		log.Println("ERROR: Invalid HTTP Method")
 	}
 }
 
+// WalletLock drops the decrypted key of the keystore wallet id.
+func (ws *WalletServer) WalletLock(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var kr KeystoreRequest
+		err := ws.lib.DecodeJSONBody(w, req, &kr)
+		if !ws.lib.IsHttpOk(err, w) {
+			return
+		}
+		if kr.ID == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus("failed: missing id")))
+			return
+		}
+		if err := ws.keystore.Lock(*kr.ID); err != nil {
+			ws.keystoreError(w, err)
+			return
+		}
+		io.WriteString(w, string(ws.lib.JsonStatus("success")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// keystoreError answers with why the keystore refused a request.
+func (ws *WalletServer) keystoreError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, wallet.ErrKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, wallet.ErrWrongPassphrase), errors.Is(err, wallet.ErrLocked):
+		status = http.StatusForbidden
+	}
+	log.Printf("ERROR: keystore: %v", err)
+	w.WriteHeader(status)
+	io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", err))))
+}
+
+// CreateTransaction signs a transaction with a keystore wallet, named by
+// wallet_id or by the sender's address, and sends it to the gateway. Unless
+// the server accepts private keys, requests carrying sender_private_key are
+// turned away.
 func (ws *WalletServer) CreateTransaction(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodPost:
@@ -77,77 +196,247 @@ func (ws *WalletServer) CreateTransaction(w http.ResponseWriter, req *http.Reque
 		if !ws.lib.IsHttpOk(err, w) {
 			return
 		}
+		if tx.SenderPrivateKey != nil && !ws.acceptPrivateKeys {
+			log.Println("ERROR: request carries a private key")
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", ErrPrivateKeyField))))
+			return
+		}
 		if !tx.Validate() {
 			log.Println("ERROR: invalid payload")
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err = io.WriteString(w, string(ws.lib.JsonStatus("failed: invalid payload")))
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus("failed: invalid payload")))
+			return
+		}
+		if !wallet.IsValidAddress(*tx.RecipientBlockchainAddress) {
+			log.Println("ERROR: invalid recipient:", *tx.RecipientBlockchainAddress)
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", wallet.ErrInvalidAddress))))
 			return
 		}
 
-		publicKey := ws.lib.PublicKeyFromString(*tx.SenderPublicKey)
-		privateKey := ws.lib.PrivateKeyFromString(*tx.SenderPrivateKey, publicKey)
-		value, err := strconv.ParseFloat(*tx.SenderSendAmount, 32)
+		var myWallet *wallet.Wallet
+		if tx.SenderPrivateKey != nil {
+			if myWallet, err = wallet.ImportPrivateKey(*tx.SenderPrivateKey); err != nil {
+				log.Println("ERROR: reading private key failed:", err)
+				w.Header().Add("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", err))))
+				return
+			}
+		} else {
+			if tx.WalletID != nil && *tx.WalletID != "" {
+				myWallet, err = ws.keystore.Wallet(*tx.WalletID)
+			} else {
+				myWallet, err = ws.keystore.WalletByAddress(*tx.SenderBlockchainAddress)
+			}
+			if err != nil {
+				w.Header().Add("Content-Type", "application/json")
+				ws.keystoreError(w, err)
+				return
+			}
+		}
+		sender, publicKeyStr := myWallet.BlockchainAddress(), myWallet.PublicKeyStr()
+		publicKey, privateKey := myWallet.PublicKey(), myWallet.PrivateKey()
+		value, err := types.ParseAmount(*tx.SenderSendAmount)
 		if err != nil {
-			log.Println("ERROR: parse amount failed")
+			log.Println("ERROR: parse amount failed:", err)
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", err))))
+			return
 		}
-		value32 := float32(value)
+		var fee types.Amount
+		if tx.SenderFee != nil && *tx.SenderFee != "" {
+			if fee, err = types.ParseAmount(*tx.SenderFee); err != nil {
+				log.Println("ERROR: parse fee failed:", err)
+				w.Header().Add("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", err))))
+				return
+			}
+		}
+
+		log.Printf("action=create_transaction status=signing sender=%s value=%s fee=%s", sender, value, fee)
 
-		fmt.Println("publicKey:", publicKey)
-		fmt.Println("privateKey:", privateKey)
-		fmt.Println("value32:", value32)
+		nonce, err := ws.nextNonce(sender)
+		if err != nil {
+			log.Println("ERROR: fetching nonce failed:", err)
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, string(ws.lib.JsonStatus("failed")))
+			return
+		}
 
-		transaction := wallet.NewTransaction(
-			privateKey,
-			publicKey,
-			*tx.SenderBlockchainAddress,
-			*tx.RecipientBlockchainAddress,
-			value32,
-		)
+		var transaction *wallet.Transaction
+		if ws.mode == block.UTXOMode {
+			available, err := ws.unspentOutputs(sender)
+			if err == nil {
+				transaction, err = wallet.NewPayment(
+					privateKey,
+					publicKey,
+					sender,
+					*tx.RecipientBlockchainAddress,
+					value,
+					fee,
+					nonce,
+					available,
+				)
+			}
+			if err != nil {
+				log.Println("ERROR: coin selection failed:", err)
+				w.Header().Add("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", err))))
+				return
+			}
+		} else {
+			transaction = wallet.NewTransaction(
+				privateKey,
+				publicKey,
+				sender,
+				*tx.RecipientBlockchainAddress,
+				value,
+				fee,
+				nonce,
+			)
+		}
 		signature := transaction.GenerateSignature()
 		signatureStr := signature.String()
 
-		btr := &block.TransactionRequest{
-			SenderBlockchainAddress:    tx.SenderBlockchainAddress,
+		ws.relay(w, &block.TransactionRequest{
+			SenderBlockchainAddress:    &sender,
 			RecipientBlockchainAddress: tx.RecipientBlockchainAddress,
-			SenderPublicKey:            tx.SenderPublicKey,
-			Value:                      &value32,
+			SenderPublicKey:            &publicKeyStr,
+			Value:                      &value,
+			Fee:                        &fee,
+			Nonce:                      &nonce,
 			Signature:                  &signatureStr,
-		}
-
-		m, _ := json.Marshal(btr)
-		buf := bytes.NewBuffer(m)
-		blockchainEndpoint := ws.Gateway() + "/transactions"
-		log.Println("INFO: Calling blockchain endpoint:", blockchainEndpoint)
-		resp, err := http.Post(blockchainEndpoint, "application/json", buf)
+			Inputs:                     transaction.Inputs(),
+			Outputs:                    transaction.Outputs(),
+		})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
 
+// SignedTransaction relays a transaction the client has signed itself, in
+// the form the nodes take it, to the gateway. The nonce and, in UTXOMode,
+// the outputs to spend are at /wallet/nonce and /wallet/utxos.
+func (ws *WalletServer) SignedTransaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		var btr block.TransactionRequest
+		err := ws.lib.DecodeJSONBody(w, req, &btr)
+		if !ws.lib.IsHttpOk(err, w) {
+			return
+		}
+		err = btr.Validate()
+		if err == nil && btr.Nonce == nil {
+			err = fmt.Errorf("%w: nonce is required", block.ErrInvalidRequest)
+		}
 		if err != nil {
-			log.Println("ERROR: error calling blockchain", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			log.Println("ERROR:", err)
 			w.Header().Add("Content-Type", "application/json")
-			io.WriteString(w, string(ws.lib.JsonStatus("failed")))
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", err))))
 			return
 		}
-
-		if resp.StatusCode == 201 {
+		if err := btr.ValidateAddresses(); err != nil {
+			log.Println("ERROR:", err)
 			w.Header().Add("Content-Type", "application/json")
-			io.WriteString(w, string(ws.lib.JsonStatus("success")))
-			log.Println("create transaction success")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", err))))
 			return
-		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-			io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", resp.StatusCode))))
-			log.Println("ERROR: create transaction failed:", resp.StatusCode)
 		}
+		ws.relay(w, &btr)
 	default:
 		w.WriteHeader(http.StatusBadRequest)
 		log.Println("ERROR: Invalid HTTP Method")
 	}
 }
 
+// relay posts a signed transaction to the gateway and answers with its id,
+// or with why the node rejected it.
+func (ws *WalletServer) relay(w http.ResponseWriter, btr *block.TransactionRequest) {
+	m, _ := json.Marshal(btr)
+	buf := bytes.NewBuffer(m)
+	blockchainEndpoint := ws.Gateway() + "/transactions"
+	log.Println("INFO: Calling blockchain endpoint:", blockchainEndpoint)
+	resp, err := http.Post(blockchainEndpoint, "application/json", buf)
+
+	if err != nil {
+		log.Println("ERROR: error calling blockchain", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(ws.lib.JsonStatus("failed")))
+		return
+	}
+	defer resp.Body.Close()
+
+	var tr block.TransactionResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&tr)
+	if resp.StatusCode == 201 {
+		m, _ := json.Marshal(&block.TransactionResponse{Message: "success", ID: tr.ID})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+		log.Println("create transaction success id:", tr.ID)
+		return
+	} else {
+		// pass on why the blockchain node rejected the transaction
+		message := fmt.Sprintf("failed: %v", resp.StatusCode)
+		if decodeErr == nil && tr.Message != "" {
+			message = tr.Message
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, string(ws.lib.JsonStatus(message)))
+		log.Println("ERROR: create transaction failed:", resp.StatusCode, message)
+	}
+}
+
+// WalletNonce proxies the nonce the next transaction of blockchain_address
+// has to carry, for clients that sign their own transactions.
+func (ws *WalletServer) WalletNonce(w http.ResponseWriter, req *http.Request) {
+	ws.proxyAddress(w, req, "nonce")
+}
+
+// WalletUTXOs proxies the unspent outputs of blockchain_address, for clients
+// that sign their own transactions.
+func (ws *WalletServer) WalletUTXOs(w http.ResponseWriter, req *http.Request) {
+	ws.proxyAddress(w, req, "utxos")
+}
+
+// proxyAddress passes on the gateway's /address/{blockchain_address}/{what}.
+func (ws *WalletServer) proxyAddress(w http.ResponseWriter, req *http.Request, what string) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add(ws.lib.GetApplicationJson())
+		blockchainAddress := req.URL.Query().Get("blockchain_address")
+		if blockchainAddress == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus("failed: missing blockchain_address")))
+			return
+		}
+		ws.proxy(w, fmt.Sprintf("%s/address/%s/%s", ws.Gateway(), url.PathEscape(blockchainAddress), what))
+	default:
+		log.Printf("ERROR: Invalid HTTP method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
 func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
 		blockchainAddress := req.URL.Query().Get("blockchain_address")
+		if ws.lightClient != nil {
+			ws.verifiedAmount(w, blockchainAddress)
+			return
+		}
 		endpoint := fmt.Sprintf("%s/amount", ws.Gateway())
 		client := &http.Client{}
 
@@ -175,8 +464,8 @@ func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
 			}
 
 			m, _ := json.Marshal(struct {
-				Message string  `json:"message"`
-				Amount  float32 `json:"amount"`
+				Message string       `json:"message"`
+				Amount  types.Amount `json:"amount"`
 			}{
 				Message: "success",
 				Amount:  bar.Amount,
@@ -193,11 +482,234 @@ func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// verifiedAmount answers WalletAmount from the balance the light client
+// could prove, along with what each node claimed.
+func (ws *WalletServer) verifiedAmount(w http.ResponseWriter, blockchainAddress string) {
+	w.Header().Add(ws.lib.GetApplicationJson())
+	check, err := ws.lightClient.CheckBalance(blockchainAddress)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, string(ws.lib.JsonStatus("fail")))
+		return
+	}
+	message := "success"
+	if !check.Consistent {
+		message = "warning: nodes disagree about this balance"
+		for _, n := range check.Nodes {
+			if n.Error != "" {
+				log.Printf("WARN: node %s: %s", n.Node, n.Error)
+			}
+		}
+	}
+	m, _ := json.Marshal(struct {
+		Message string              `json:"message"`
+		Amount  types.Amount        `json:"amount"`
+		Check   *block.BalanceCheck `json:"check"`
+	}{
+		Message: message,
+		Amount:  check.Balance,
+		Check:   check,
+	})
+	io.WriteString(w, string(m[:]))
+}
+
+// WalletTransaction tells whether the transaction ?id= was mined. With a light
+// client the answer is proven against the verified headers, otherwise it is
+// the gateway's.
+func (ws *WalletServer) WalletTransaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add(ws.lib.GetApplicationJson())
+		id, err := types.ParseByte32(req.URL.Query().Get("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus(fmt.Sprintf("failed: %v", err))))
+			return
+		}
+		if ws.lightClient == nil {
+			ws.proxy(w, fmt.Sprintf("%s/transactions/%x", ws.Gateway(), id))
+			return
+		}
+		check, err := ws.lightClient.CheckTransaction(id)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, string(ws.lib.JsonStatus("fail")))
+			return
+		}
+		if !check.Consistent {
+			log.Printf("WARN: nodes disagree about transaction %s", check.ID)
+		}
+		m, _ := json.Marshal(check)
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// WalletNodes syncs the light client's headers and reports how every node's
+// headers fared.
+func (ws *WalletServer) WalletNodes(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add(ws.lib.GetApplicationJson())
+		if ws.lightClient == nil {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(ws.lib.JsonStatus("failed: light client mode is off")))
+			return
+		}
+		statuses := ws.lightClient.Sync()
+		m, _ := json.Marshal(struct {
+			Height int                 `json:"height"`
+			Nodes  []*block.NodeStatus `json:"nodes"`
+		}{
+			Height: ws.lightClient.Height(),
+			Nodes:  statuses,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// nextNonce asks the gateway which nonce the next transaction of
+// blockchainAddress has to carry.
+func (ws *WalletServer) nextNonce(blockchainAddress string) (uint64, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/address/%s/nonce", ws.Gateway(), url.PathEscape(blockchainAddress)))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("gateway answered %d", resp.StatusCode)
+	}
+	var nr block.NonceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&nr); err != nil {
+		return 0, err
+	}
+	return nr.Nonce, nil
+}
+
+// unspentOutputs asks the gateway which outputs of blockchainAddress are
+// there to spend.
+func (ws *WalletServer) unspentOutputs(blockchainAddress string) ([]types.UnspentOutput, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/address/%s/utxos", ws.Gateway(), url.PathEscape(blockchainAddress)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gateway answered %d", resp.StatusCode)
+	}
+	var ur block.UnspentOutputsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ur); err != nil {
+		return nil, err
+	}
+	return ur.Outputs, nil
+}
+
+// proxy passes the gateway's answer to GET endpoint on unchanged.
+func (ws *WalletServer) proxy(w http.ResponseWriter, endpoint string) {
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, string(ws.lib.JsonStatus("fail")))
+		return
+	}
+	defer resp.Body.Close()
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// syncHeaders keeps the light client's headers current in the background.
+func (ws *WalletServer) syncHeaders() {
+	for {
+		ws.lightClient.Sync()
+		time.Sleep(time.Second * block.BlockchainNeighborSyncTimeSec)
+	}
+}
+
+// WalletHistory proxies the address history of blockchain_address, one page
+// per request, continuing at cursor when given.
+func (ws *WalletServer) WalletHistory(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add(ws.lib.GetApplicationJson())
+		blockchainAddress := req.URL.Query().Get("blockchain_address")
+		if blockchainAddress == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus("failed: missing blockchain_address")))
+			return
+		}
+		endpoint := fmt.Sprintf("%s/address/%s/transactions", ws.Gateway(), url.PathEscape(blockchainAddress))
+		bcsReq, _ := http.NewRequest("GET", endpoint, nil)
+		q := bcsReq.URL.Query()
+		if cursor := req.URL.Query().Get("cursor"); cursor != "" {
+			q.Add("cursor", cursor)
+		}
+		bcsReq.URL.RawQuery = q.Encode()
+
+		bcsResp, err := http.DefaultClient.Do(bcsReq)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, string(ws.lib.JsonStatus("fail")))
+			return
+		}
+		defer bcsResp.Body.Close()
+		if bcsResp.StatusCode != http.StatusOK {
+			log.Printf("ERROR: history request failed: %d", bcsResp.StatusCode)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus("fail")))
+			return
+		}
+		var history block.AddressHistory
+		if err := json.NewDecoder(bcsResp.Body).Decode(&history); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(ws.lib.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Message string `json:"message"`
+			*block.AddressHistory
+		}{
+			Message:        "success",
+			AddressHistory: &history,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Printf("ERROR: Invalid HTTP method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (ws *WalletServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", ws.Index)
+	mux.HandleFunc("/wallet", ws.Wallet)
+	mux.HandleFunc("/wallet/unlock", ws.WalletUnlock)
+	mux.HandleFunc("/wallet/lock", ws.WalletLock)
+	mux.HandleFunc("/wallet/amount", ws.WalletAmount)
+	mux.HandleFunc("/wallet/history", ws.WalletHistory)
+	mux.HandleFunc("/wallet/transaction", ws.WalletTransaction)
+	mux.HandleFunc("/wallet/nodes", ws.WalletNodes)
+	mux.HandleFunc("/wallet/nonce", ws.WalletNonce)
+	mux.HandleFunc("/wallet/utxos", ws.WalletUTXOs)
+	mux.HandleFunc("/transaction", ws.CreateTransaction)
+	mux.HandleFunc("/transaction/signed", ws.SignedTransaction)
+	return mux
+}
+
 func (ws *WalletServer) Run() {
-	http.HandleFunc("/", ws.Index)
-	http.HandleFunc("/wallet", ws.Wallet)
-	http.HandleFunc("/wallet/amount", ws.WalletAmount)
-	http.HandleFunc("/transaction", ws.CreateTransaction)
+	if ws.lightClient != nil {
+		log.Printf("INFO: light client mode, checking against %v", ws.lightClient.Nodes())
+		go ws.syncHeaders()
+	}
 	log.Printf("Running wallet server on port %v\n", ws.Port())
-	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(ws.Port())), nil))
+	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(ws.Port())), ws.Handler()))
 }
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"blockchain/block"
+	types "blockchain/blockchaintypes"
+	"blockchain/globals"
+	"blockchain/wallet"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const gatewayNonce uint64 = 3
+
+// gateway stands in for a blockchain node. It hands out gatewayNonce for
+// every address and takes the transactions whose signature verifies against
+// their sender's key, keeping them in received.
+type gateway struct {
+	server   *httptest.Server
+	mux      sync.Mutex
+	received []*block.TransactionRequest
+}
+
+func startGateway(t *testing.T) *gateway {
+	g := new(gateway)
+	lib := &globals.GlobalLib{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/address/", func(w http.ResponseWriter, req *http.Request) {
+		address := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/address/"), "/nonce")
+		m, _ := json.Marshal(&block.NonceResponse{Address: address, Nonce: gatewayNonce})
+		w.Write(m)
+	})
+	mux.HandleFunc("/transactions", func(w http.ResponseWriter, req *http.Request) {
+		var btr block.TransactionRequest
+		if err := json.NewDecoder(req.Body).Decode(&btr); err != nil || btr.Validate() != nil || btr.Nonce == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(lib.JsonStatus("failed: invalid payload"))
+			return
+		}
+		var fee types.Amount
+		if btr.Fee != nil {
+			fee = *btr.Fee
+		}
+		publicKey, signature := lib.PublicKeyFromString(*btr.SenderPublicKey), lib.SignatureFromString(*btr.Signature)
+		t := block.NewSignedTransaction(*btr.SenderBlockchainAddress, *btr.RecipientBlockchainAddress, *btr.Value, fee, *btr.Nonce, publicKey, signature)
+		m, _ := t.SignedPayload()
+		h := sha256.Sum256(m)
+		if !ecdsa.Verify(publicKey, h[:], signature.R, signature.S) || wallet.AddressFromPublicKey(publicKey) != *btr.SenderBlockchainAddress {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(lib.JsonStatus("failed: " + block.ErrInvalidSignature.Error()))
+			return
+		}
+		g.mux.Lock()
+		g.received = append(g.received, &btr)
+		g.mux.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		m, _ = json.Marshal(&block.TransactionResponse{Message: "success", ID: fmt.Sprintf("%x", t.ID())})
+		w.Write(m)
+	})
+	g.server = httptest.NewServer(mux)
+	t.Cleanup(g.server.Close)
+	return g
+}
+
+// relayed returns the transactions the gateway took so far.
+func (g *gateway) relayed() []*block.TransactionRequest {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return append([]*block.TransactionRequest(nil), g.received...)
+}
+
+// startWalletServer runs a wallet server with an empty keystore in front of
+// gw.
+func startWalletServer(t *testing.T, gw *gateway, acceptPrivateKeys bool) (*httptest.Server, *wallet.Keystore) {
+	keystore, err := wallet.NewKeystore(t.TempDir(), wallet.LightScryptN, wallet.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := NewWalletServer(0, gw.server.URL, &globals.GlobalLib{}, nil, block.AccountMode, keystore, acceptPrivateKeys)
+	server := httptest.NewServer(ws.Handler())
+	t.Cleanup(server.Close)
+	return server, keystore
+}
+
+// post sends v to url as JSON and returns the status code and message of
+// the answer.
+func post(url string, v interface{}) (int, string) {
+	m, _ := json.Marshal(v)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(m))
+	So(err, ShouldBeNil)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	var status struct {
+		Message string `json:"message"`
+	}
+	json.Unmarshal(body, &status)
+	return resp.StatusCode, status.Message
+}
+
+func TestWalletServer_PrivateKeys(t *testing.T) {
+	sender := wallet.NewWallet()
+	recipient := wallet.NewWallet().BlockchainAddress()
+	request := map[string]string{
+		"sender_private_key":           sender.ExportHex(),
+		"recipient_blockchain_address": recipient,
+		"sender_send_amount":           "1.5",
+	}
+
+	Convey("sender_private_key is turned away by default", t, func() {
+		gw := startGateway(t)
+		server, _ := startWalletServer(t, gw, false)
+		status, message := post(server.URL+"/transaction", request)
+		So(status, ShouldEqual, http.StatusBadRequest)
+		So(message, ShouldEqual, "failed: "+ErrPrivateKeyField.Error())
+		So(gw.relayed(), ShouldBeEmpty)
+	})
+
+	Convey("sender_private_key signs when the server accepts private keys", t, func() {
+		gw := startGateway(t)
+		server, _ := startWalletServer(t, gw, true)
+		status, message := post(server.URL+"/transaction", request)
+		So(status, ShouldEqual, http.StatusOK)
+		So(message, ShouldEqual, "success")
+		relayed := gw.relayed()
+		So(relayed, ShouldHaveLength, 1)
+		So(*relayed[0].SenderBlockchainAddress, ShouldEqual, sender.BlockchainAddress())
+		So(*relayed[0].Value, ShouldEqual, types.Coin+types.Coin/2)
+		So(*relayed[0].Nonce, ShouldEqual, gatewayNonce)
+	})
+
+	Convey("an incomplete request is a bad request", t, func() {
+		gw := startGateway(t)
+		server, _ := startWalletServer(t, gw, true)
+		status, message := post(server.URL+"/transaction", map[string]string{"sender_private_key": sender.ExportHex()})
+		So(status, ShouldEqual, http.StatusBadRequest)
+		So(message, ShouldEqual, "failed: invalid payload")
+	})
+}
+
+func TestWalletServer_Keystore(t *testing.T) {
+	recipient := wallet.NewWallet().BlockchainAddress()
+	gw := startGateway(t)
+	server, keystore := startWalletServer(t, gw, false)
+	info, err := keystore.New("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(by map[string]string) (int, string) {
+		by["recipient_blockchain_address"] = recipient
+		by["sender_send_amount"] = "2"
+		return post(server.URL+"/transaction", by)
+	}
+
+	Convey("an unlocked wallet signs by id or by address", t, func() {
+		status, _ := send(map[string]string{"wallet_id": info.ID})
+		So(status, ShouldEqual, http.StatusOK)
+		status, _ = send(map[string]string{"sender_blockchain_address": info.BlockchainAddress})
+		So(status, ShouldEqual, http.StatusOK)
+		for _, relayed := range gw.relayed() {
+			So(*relayed.SenderBlockchainAddress, ShouldEqual, info.BlockchainAddress)
+			So(*relayed.SenderPublicKey, ShouldEqual, info.PublicKey)
+		}
+	})
+
+	Convey("a locked wallet doesn't sign until it is unlocked", t, func() {
+		before := len(gw.relayed())
+		status, _ := post(server.URL+"/wallet/lock", map[string]string{"id": info.ID})
+		So(status, ShouldEqual, http.StatusOK)
+		status, message := send(map[string]string{"wallet_id": info.ID})
+		So(status, ShouldEqual, http.StatusForbidden)
+		So(message, ShouldContainSubstring, wallet.ErrLocked.Error())
+
+		status, _ = post(server.URL+"/wallet/unlock", map[string]string{"id": info.ID, "passphrase": "wrong"})
+		So(status, ShouldEqual, http.StatusForbidden)
+		status, _ = send(map[string]string{"wallet_id": info.ID})
+		So(status, ShouldEqual, http.StatusForbidden)
+		So(gw.relayed(), ShouldHaveLength, before)
+
+		status, _ = post(server.URL+"/wallet/unlock", map[string]string{"id": info.ID, "passphrase": "secret"})
+		So(status, ShouldEqual, http.StatusOK)
+		status, _ = send(map[string]string{"wallet_id": info.ID})
+		So(status, ShouldEqual, http.StatusOK)
+		So(gw.relayed(), ShouldHaveLength, before+1)
+	})
+
+	Convey("an unknown wallet is not found", t, func() {
+		status, _ := send(map[string]string{"wallet_id": "nobody"})
+		So(status, ShouldEqual, http.StatusNotFound)
+	})
+}
+
+func TestWalletServer_SignedTransaction(t *testing.T) {
+	sender := wallet.NewWallet()
+	recipient := wallet.NewWallet().BlockchainAddress()
+	gw := startGateway(t)
+	server, _ := startWalletServer(t, gw, false)
+
+	sign := func(value types.Amount, nonce uint64) *block.TransactionRequest {
+		tx := wallet.NewTransaction(sender.PrivateKey(), sender.PublicKey(), sender.BlockchainAddress(), recipient, value, 0, nonce)
+		address, publicKey, signature := sender.BlockchainAddress(), sender.PublicKeyStr(), tx.GenerateSignature().String()
+		return &block.TransactionRequest{
+			SenderBlockchainAddress:    &address,
+			RecipientBlockchainAddress: &recipient,
+			SenderPublicKey:            &publicKey,
+			Value:                      &value,
+			Nonce:                      &nonce,
+			Signature:                  &signature,
+		}
+	}
+
+	Convey("a transaction signed by the client is relayed as it is", t, func() {
+		btr := sign(types.Coin, 7)
+		status, message := post(server.URL+"/transaction/signed", btr)
+		So(status, ShouldEqual, http.StatusOK)
+		So(message, ShouldEqual, "success")
+		relayed := gw.relayed()
+		So(relayed, ShouldHaveLength, 1)
+		So(*relayed[0].Signature, ShouldEqual, *btr.Signature)
+		So(*relayed[0].Nonce, ShouldEqual, 7)
+	})
+
+	Convey("incomplete or malformed transactions don't reach the gateway", t, func() {
+		before := len(gw.relayed())
+		noNonce := sign(types.Coin, 0)
+		noNonce.Nonce = nil
+		status, message := post(server.URL+"/transaction/signed", noNonce)
+		So(status, ShouldEqual, http.StatusBadRequest)
+		So(message, ShouldEqual, "failed: "+block.ErrInvalidRequest.Error()+": nonce is required")
+
+		badRecipient := sign(types.Coin, 0)
+		to := "THE BLOCKCHAIN"
+		badRecipient.RecipientBlockchainAddress = &to
+		status, _ = post(server.URL+"/transaction/signed", badRecipient)
+		So(status, ShouldEqual, http.StatusBadRequest)
+		So(gw.relayed(), ShouldHaveLength, before)
+	})
+
+	Convey("the gateway's reason for a rejection is passed on", t, func() {
+		forged := sign(types.Coin, 0)
+		more := 100 * types.Coin
+		forged.Value = &more
+		status, message := post(server.URL+"/transaction/signed", forged)
+		So(status, ShouldEqual, http.StatusInternalServerError)
+		So(message, ShouldEqual, "failed: "+block.ErrInvalidSignature.Error())
+	})
+}